@@ -0,0 +1,34 @@
+package alerts
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	EvaluateInterval = 30 * time.Second
+	MaxRulesPerUser  = 50
+
+	WebhookTimeout = 5 * time.Second
+	WebhookRetries = 2
+	// Webhooks to loopback and private addresses are refused unless enabled,
+	// so alert rules cannot be used to reach internal services.
+	AllowPrivateWebhooks = false
+)
+
+func init() {
+	if v := os.Getenv("ALERTS_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			EvaluateInterval = d
+		}
+	}
+	if v := os.Getenv("ALERTS_MAX_PER_USER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			MaxRulesPerUser = n
+		}
+	}
+	if v := os.Getenv("ALERTS_ALLOW_PRIVATE_WEBHOOKS"); v == "true" || v == "1" {
+		AllowPrivateWebhooks = true
+	}
+}
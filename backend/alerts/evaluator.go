@@ -0,0 +1,143 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"coin-wave/models"
+	"coin-wave/rates"
+
+	"gorm.io/gorm"
+)
+
+const (
+	Above = "above"
+	Below = "below"
+)
+
+// Evaluator checks alert rules against the rates cache. Rules are edge
+// triggered: a rule fires when the price crosses its threshold, not on every
+// evaluation while it stays past it.
+type Evaluator struct {
+	db       *gorm.DB
+	rates    *rates.Service
+	webhooks *webhookSender
+}
+
+func NewEvaluator(db *gorm.DB, rateService *rates.Service) *Evaluator {
+	return &Evaluator{db: db, rates: rateService, webhooks: newWebhookSender()}
+}
+
+// Start evaluates every EvaluateInterval until ctx is cancelled.
+func (e *Evaluator) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(EvaluateInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.Evaluate(ctx)
+			}
+		}
+	}()
+	log.Printf("[Alerts] Evaluating every %s", EvaluateInterval)
+}
+
+// Evaluate fires the armed rules whose condition holds and re-arms recurring
+// rules whose condition no longer does. Rules are skipped while their rate is
+// stale or unavailable.
+func (e *Evaluator) Evaluate(ctx context.Context) {
+	prices := make(map[string]*rates.Conversion)
+	price := func(symbol, currency string) *rates.Conversion {
+		key := symbol + "/" + currency
+		if conv, ok := prices[key]; ok {
+			return conv
+		}
+		conv, err := e.rates.Convert(ctx, symbol, currency)
+		if err != nil || conv.Stale {
+			prices[key] = nil
+			return nil
+		}
+		prices[key] = &conv
+		return &conv
+	}
+
+	var batch []models.AlertRule
+	err := e.db.Where("active = ?", true).FindInBatches(&batch, 500, func(_ *gorm.DB, _ int) error {
+		for i := range batch {
+			rule := &batch[i]
+			conv := price(rule.Symbol, rule.Currency)
+			if conv == nil {
+				continue
+			}
+			hit := Holds(rule, conv.Rate.Float64())
+			switch {
+			case hit && rule.Armed:
+				e.fire(rule, conv.Rate.Float64())
+			case !hit && !rule.Armed && rule.Recurring:
+				e.db.Model(&models.AlertRule{}).Where("id = ? AND armed = ?", rule.ID, false).Update("armed", true)
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Printf("[Alerts] Evaluation failed: %v", err)
+	}
+}
+
+// Holds reports whether price is past the rule's threshold.
+func Holds(rule *models.AlertRule, price float64) bool {
+	if rule.Comparison == Below {
+		return price <= rule.Threshold
+	}
+	return price >= rule.Threshold
+}
+
+// Arm sets whether a new or edited rule waits for the next crossing: it is
+// disarmed while its condition already holds, so it does not fire straight
+// away. Rules are armed if the price is unknown.
+func (e *Evaluator) Arm(ctx context.Context, rule *models.AlertRule) {
+	rule.Armed = true
+	if conv, err := e.rates.Convert(ctx, rule.Symbol, rule.Currency); err == nil {
+		rule.Armed = !Holds(rule, conv.Rate.Float64())
+	}
+}
+
+func (e *Evaluator) fire(rule *models.AlertRule, price float64) {
+	now := time.Now()
+	notification := models.Notification{
+		UserID: rule.UserID,
+		RuleID: rule.ID,
+		Title:  fmt.Sprintf("%s %s %s %s", rule.Symbol, rule.Comparison, formatAmount(rule.Threshold), rule.Currency),
+		Body: fmt.Sprintf("%s is at %s %s, %s your alert threshold of %s %s.",
+			rule.Symbol, formatAmount(price), rule.Currency, rule.Comparison, formatAmount(rule.Threshold), rule.Currency),
+	}
+
+	fired := false
+	err := e.db.Transaction(func(tx *gorm.DB) error {
+		// Guard on armed so a rule fires once even with several instances
+		res := tx.Model(&models.AlertRule{}).Where("id = ? AND armed = ? AND active = ?", rule.ID, true, true).
+			Updates(map[string]interface{}{"armed": false, "active": rule.Recurring, "last_triggered_at": now})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		fired = true
+		return tx.Create(&notification).Error
+	})
+	if err != nil {
+		log.Printf("[Alerts] Failed to fire rule %d: %v", rule.ID, err)
+		return
+	}
+	if fired && rule.WebhookURL != "" {
+		go e.webhooks.send(rule, price, now)
+	}
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
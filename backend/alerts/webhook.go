@@ -0,0 +1,88 @@
+package alerts
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"coin-wave/models"
+
+	"github.com/go-resty/resty/v2"
+)
+
+var errBlockedAddress = errors.New("webhook address not allowed")
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http(s) URL.
+// Addresses are checked again when connecting, as DNS can change.
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("webhook URL must be an http or https URL")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !allowedIP(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+type webhookSender struct {
+	client *resty.Client
+}
+
+func newWebhookSender() *webhookSender {
+	dialer := &net.Dialer{
+		Timeout: WebhookTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowedIP(ip) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+	client := resty.New().
+		SetTransport(&http.Transport{DialContext: dialer.DialContext}).
+		SetTimeout(WebhookTimeout).
+		SetRetryCount(WebhookRetries)
+	return &webhookSender{client: client}
+}
+
+func (w *webhookSender) send(rule *models.AlertRule, price float64, at time.Time) {
+	resp, err := w.client.R().
+		SetHeader("X-CoinWave-Event", "alert.triggered").
+		SetBody(map[string]interface{}{
+			"rule_id":      rule.ID,
+			"symbol":       rule.Symbol,
+			"currency":     rule.Currency,
+			"comparison":   rule.Comparison,
+			"threshold":    rule.Threshold,
+			"price":        price,
+			"triggered_at": at,
+		}).
+		Post(rule.WebhookURL)
+	if err != nil {
+		log.Printf("[Alerts] Webhook for rule %d failed: %v", rule.ID, err)
+		return
+	}
+	if resp.IsError() {
+		log.Printf("[Alerts] Webhook for rule %d returned %s", rule.ID, resp.Status())
+	}
+}
+
+func allowedIP(ip net.IP) bool {
+	if AllowPrivateWebhooks {
+		return true
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast())
+}
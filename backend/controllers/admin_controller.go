@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+
+	"coin-wave/database"
+	"coin-wave/ledger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckLedger runs the ledger invariant checker. It responds 200 when the
+// ledger is consistent and 409 with the offending entries and accounts
+// otherwise.
+func CheckLedger(c *gin.Context) {
+	report, err := ledger.Check(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ledger check failed"})
+		return
+	}
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"data": report})
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"coin-wave/alerts"
+	"coin-wave/database"
+	"coin-wave/models"
+	"coin-wave/rates"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxNotifications = 100
+
+type CreateAlertInput struct {
+	Symbol     string  `json:"symbol" binding:"required"`
+	Currency   string  `json:"currency"` // Defaults to USD
+	Comparison string  `json:"comparison" binding:"required,oneof=above below"`
+	Threshold  float64 `json:"threshold" binding:"required,gt=0"`
+	Recurring  bool    `json:"recurring"`
+	WebhookURL string  `json:"webhook_url"`
+}
+
+type UpdateAlertInput struct {
+	Comparison *string  `json:"comparison" binding:"omitempty,oneof=above below"`
+	Threshold  *float64 `json:"threshold" binding:"omitempty,gt=0"`
+	Recurring  *bool    `json:"recurring"`
+	Active     *bool    `json:"active"`
+	WebhookURL *string  `json:"webhook_url"`
+}
+
+func GetAlerts(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var rules []models.AlertRule
+	if err := database.DB.Where("user_id = ?", userID).Order("id desc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// CreateAlert adds a price alert. It fires the next time the price of symbol
+// in currency crosses the threshold, then stays quiet until it re-arms
+// (recurring) or for good (one-shot).
+func CreateAlert(c *gin.Context) {
+	var input CreateAlertInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	rule := models.AlertRule{
+		UserID:     userID.(uint),
+		Symbol:     strings.ToUpper(input.Symbol),
+		Currency:   strings.ToUpper(input.Currency),
+		Comparison: input.Comparison,
+		Threshold:  input.Threshold,
+		Recurring:  input.Recurring,
+		Active:     true,
+		WebhookURL: input.WebhookURL,
+	}
+	if rule.Currency == "" {
+		rule.Currency = "USD"
+	}
+	if !rates.Known(rule.Symbol) || !rates.Supported(rule.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown currency"})
+		return
+	}
+	if rule.WebhookURL != "" {
+		if err := alerts.ValidateWebhookURL(rule.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook URL"})
+			return
+		}
+	}
+
+	var count int64
+	database.DB.Model(&models.AlertRule{}).Where("user_id = ?", rule.UserID).Count(&count)
+	if count >= int64(alerts.MaxRulesPerUser) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many alerts"})
+		return
+	}
+
+	AlertEvaluator.Arm(c.Request.Context(), &rule)
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+func UpdateAlert(c *gin.Context) {
+	var input UpdateAlertInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule, ok := findAlertRule(c)
+	if !ok {
+		return
+	}
+
+	rearm := false
+	if input.Comparison != nil {
+		rule.Comparison = *input.Comparison
+		rearm = true
+	}
+	if input.Threshold != nil {
+		rule.Threshold = *input.Threshold
+		rearm = true
+	}
+	if input.Recurring != nil {
+		rule.Recurring = *input.Recurring
+	}
+	if input.Active != nil {
+		rearm = rearm || *input.Active && !rule.Active
+		rule.Active = *input.Active
+	}
+	if input.WebhookURL != nil {
+		if *input.WebhookURL != "" {
+			if err := alerts.ValidateWebhookURL(*input.WebhookURL); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook URL"})
+				return
+			}
+		}
+		rule.WebhookURL = *input.WebhookURL
+	}
+	if rearm {
+		AlertEvaluator.Arm(c.Request.Context(), rule)
+	}
+
+	if err := database.DB.Save(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+func DeleteAlert(c *gin.Context) {
+	rule, ok := findAlertRule(c)
+	if !ok {
+		return
+	}
+	if err := database.DB.Delete(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Alert deleted"})
+}
+
+// GetNotifications lists the inbox newest first. Query params: unread=true,
+// limit (default and max 100) and before, the next_cursor of the previous page.
+func GetNotifications(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	limit := maxNotifications
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v < limit {
+		limit = v
+	}
+	query := database.DB.Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	if v := c.Query("before"); v != "" {
+		before, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query = query.Where("id < ?", before)
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("id desc").Limit(limit + 1).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	var nextCursor string
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		nextCursor = strconv.FormatUint(uint64(notifications[limit-1].ID), 10)
+	}
+
+	var unread int64
+	database.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	c.JSON(http.StatusOK, gin.H{"data": notifications, "next_cursor": nextCursor, "unread": unread})
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	res := database.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", c.Param("id"), userID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	res := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "updated": res.RowsAffected})
+}
+
+func findAlertRule(c *gin.Context) (*models.AlertRule, bool) {
+	userID, _ := c.Get("userID")
+
+	var rule models.AlertRule
+	if err := database.DB.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return nil, false
+	}
+	if rule.UserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
+		return nil, false
+	}
+	return &rule, true
+}
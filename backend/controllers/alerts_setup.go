@@ -0,0 +1,10 @@
+package controllers
+
+import "coin-wave/alerts"
+
+var AlertEvaluator *alerts.Evaluator
+
+// InitAlerts sets the evaluator used to arm rules as they are created.
+func InitAlerts(evaluator *alerts.Evaluator) {
+	AlertEvaluator = evaluator
+}
@@ -1,25 +1,31 @@
 package controllers
 
 import (
-	"coin-wave/database"
-	"coin-wave/models"
+	"html"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"coin-wave/database"
+	"coin-wave/models"
+	"coin-wave/money"
+	"coin-wave/rates"
+	"coin-wave/search"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
-	"math"
-	"sort"
-	"strings"
-	"time"
+	"gorm.io/gorm/clause"
 )
 
 type CreateArticleInput struct {
-	Title   string  `json:"title" binding:"required"`
-	Content string  `json:"content" binding:"required"`
-	Tags    string  `json:"tags" binding:"required"`
-	IsPaid  bool    `json:"is_paid"`
-	Price   float64 `json:"price"`
+	Title         string        `json:"title" binding:"required"`
+	Content       string        `json:"content" binding:"required"`
+	Tags          string        `json:"tags" binding:"required"`
+	IsPaid        bool          `json:"is_paid"`
+	Price         money.Decimal `json:"price"`
+	PriceCurrency string        `json:"price_currency"` // Defaults to the wallet currency
 }
 
 func CreateArticle(c *gin.Context) {
@@ -29,6 +35,19 @@ func CreateArticle(c *gin.Context) {
 		return
 	}
 
+	if input.Price.Sign() < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price cannot be negative"})
+		return
+	}
+	currency := strings.ToUpper(input.PriceCurrency)
+	if currency == "" {
+		currency = rates.WalletCurrency
+	}
+	if !rates.Supported(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported price currency"})
+		return
+	}
+
 	userID, _ := c.Get("userID")
 	user := models.User{}
 	if err := database.DB.First(&user, userID).Error; err != nil {
@@ -37,148 +56,304 @@ func CreateArticle(c *gin.Context) {
 	}
 
 	article := models.Article{
-		Title:    input.Title,
-		Content:  input.Content,
-		AuthorID: user.ID,
-		Tags:     input.Tags,
-		IsPaid:   input.IsPaid,
-		Price:    input.Price,
+		Title:         input.Title,
+		Content:       input.Content,
+		AuthorID:      user.ID,
+		Tags:          input.Tags,
+		IsPaid:        input.IsPaid,
+		Price:         input.Price,
+		PriceCurrency: currency,
 	}
 
 	if err := database.DB.Create(&article).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create article"})
 		return
 	}
+	ArticleSearch.Add(articleDoc(&article, user.Username))
 
 	// Trigger Async Vectorization
-	if RagWorker != nil {
-		RagWorker.Run(c.Request.Context(), article.ID)
+	if RagQueue != nil {
+		if err := RagQueue.Enqueue(article.ID); err != nil {
+			log.Printf("Failed to enqueue ingestion for article %d: %v", article.ID, err)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": article})
 }
 
+// Article list settings
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	teaserLength    = 120 // Runes of paid content shown in lists without access
+	snippetLength   = 160
+)
+
 func GetArticles(c *gin.Context) {
-	// Common base query
-	db := database.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
+	limit := defaultPageSize
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, maxPageSize)
+	}
+
+	var cursor *search.Cursor
+	if v := c.Query("cursor"); v != "" {
+		cur, err := search.DecodeCursor(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		cursor = &cur
+	}
+
+	if q := strings.TrimSpace(c.Query("search")); q != "" {
+		searchArticles(c, q, limit, cursor)
+		return
+	}
+
+	// Default Logic (No Search)
+	query := database.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, username") // Omit password
 	})
 
-	// Search Logic (Recall -> Rank -> Strategy)
-	if search := c.Query("search"); search != "" {
-		var candidates []models.Article
-		keywords := strings.Fields(strings.ToLower(search))
+	// Filter by free/paid
+	if typeParam := c.Query("type"); typeParam == "free" {
+		query = query.Where("is_paid = ?", false)
+	} else if typeParam == "paid" {
+		query = query.Where("is_paid = ?", true)
+	}
 
-		if len(keywords) == 0 {
-			c.JSON(http.StatusOK, gin.H{"data": []models.Article{}})
-			return
+	// Sort, keyset paginated on (sort key, id)
+	byRank := c.Query("sort") == "rank"
+	if byRank {
+		query = query.Order("bookmark_count desc, id desc")
+		if cursor != nil {
+			query = query.Where("bookmark_count < ? OR (bookmark_count = ? AND id < ?)", cursor.Score, cursor.Score, cursor.ID)
+		}
+	} else {
+		// Newest first; IDs grow with created_at
+		query = query.Order("id desc")
+		if cursor != nil {
+			query = query.Where("id < ?", cursor.ID)
 		}
+	}
 
-		// 1. Recall: Broad match using SQL
-		recallQuery := db
+	var articles []models.Article
+	if err := query.Limit(limit + 1).Find(&articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch articles"})
+		return
+	}
 
-		// Apply Filters (Free/Paid) during Recall
-		if typeParam := c.Query("type"); typeParam == "free" {
-			recallQuery = recallQuery.Where("is_paid = ?", false)
-		} else if typeParam == "paid" {
-			recallQuery = recallQuery.Where("is_paid = ?", true)
+	nextCursor := ""
+	if len(articles) > limit {
+		articles = articles[:limit]
+		last := articles[limit-1]
+		next := search.Cursor{ID: last.ID}
+		if byRank {
+			next.Score = float64(last.BookmarkCount)
 		}
+		nextCursor = next.Encode()
+	}
+	hideLockedContent(c, articles)
 
-		// Build OR query for all keywords
-		conditions := make([]string, 0)
-		args := make([]interface{}, 0)
-		for _, kw := range keywords {
-			pattern := "%" + kw + "%"
-			conditions = append(conditions, "title LIKE ? OR content LIKE ? OR tags LIKE ?")
-			args = append(args, pattern, pattern, pattern)
-		}
+	c.JSON(http.StatusOK, gin.H{"data": articles, "next_cursor": nextCursor})
+}
 
-		if len(conditions) > 0 {
-			recallQuery = recallQuery.Where(strings.Join(conditions, " OR "), args...)
+// searchArticles returns one page of the index matches, ranked by BM25 plus
+// popularity, with facets, highlighted snippets and a spelling suggestion.
+func searchArticles(c *gin.Context, q string, limit int, cursor *search.Cursor) {
+	query := search.ArticleQuery{
+		Text:  q,
+		Type:  c.Query("type"),
+		Tag:   c.Query("tag"),
+		Price: c.Query("price"),
+		Rates: walletRates(c.Request.Context()),
+	}
+	if v := c.Query("author_id"); v != "" {
+		authorID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author_id"})
+			return
 		}
+		query.AuthorID = uint(authorID)
+	}
+
+	page, err := ArticleSearch.SearchPage(query, cursor, limit, articlePopularity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+	nextCursor := ""
+	if page.Next != nil {
+		nextCursor = page.Next.Encode()
+	}
 
-		if err := recallQuery.Find(&candidates).Error; err != nil {
+	pageIDs := make([]uint, len(page.Hits))
+	for i, h := range page.Hits {
+		pageIDs[i] = h.ID
+	}
+	var found []models.Article
+	if len(pageIDs) > 0 {
+		if err := database.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username") // Omit password
+		}).Where("id IN ?", pageIDs).Find(&found).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
 			return
 		}
-
-		// 2. Rank: In-memory scoring
-		type ScoredArticle struct {
-			Article models.Article
-			Score   float64
+	}
+	byID := make(map[uint]models.Article, len(found))
+	for _, a := range found {
+		byID[a.ID] = a
+	}
+	results := make([]models.Article, 0, len(page.Hits))
+	for _, h := range page.Hits {
+		if a, ok := byID[h.ID]; ok {
+			results = append(results, a)
 		}
+	}
 
-		scored := make([]ScoredArticle, 0, len(candidates))
-
-		for _, art := range candidates {
-			score := 0.0
-			titleLower := strings.ToLower(art.Title)
-			contentLower := strings.ToLower(art.Content)
-			tagsLower := strings.ToLower(art.Tags)
-
-			for _, kw := range keywords {
-				// Title Weight: 10
-				if strings.Contains(titleLower, kw) {
-					score += 10
-					if titleLower == kw {
-						score += 5
-					} // Exact match bonus
-				}
-				// Tags Weight: 8
-				if strings.Contains(tagsLower, kw) {
-					score += 8
-				}
-				// Content Weight: 1 per occurrence (capped)
-				count := strings.Count(contentLower, kw)
-				score += math.Min(float64(count), 10.0)
-			}
+	// Snippets come from the visible content only, never from locked text
+	hideLockedContent(c, results)
+	snippets := make(map[uint]string, len(results))
+	for _, a := range results {
+		snippets[a.ID] = search.Snippet(a.Content, q, snippetLength)
+	}
 
-			// Popularity Weight
-			score += float64(art.BookmarkCount) * 2.0
-			score += float64(art.ViewCount) * 0.1
+	c.JSON(http.StatusOK, gin.H{
+		"data":        results,
+		"next_cursor": nextCursor,
+		"total":       page.Total,
+		"facets":      page.Facets,
+		"snippets":    snippets,
+		"suggestion":  ArticleSearch.Suggest(q),
+	})
+}
+
+// hideLockedContent replaces the content of paid articles the caller cannot
+// read with a short teaser, following the access rules of GetArticle.
+// It returns the IDs of those articles.
+func hideLockedContent(c *gin.Context, articles []models.Article) map[uint]bool {
+	var uid uint
+	if userID, exists := c.Get("userID"); exists {
+		uid = userID.(uint)
+	}
 
-			scored = append(scored, ScoredArticle{art, score})
+	var paidIDs []uint
+	for _, a := range articles {
+		if a.IsPaid && a.AuthorID != uid {
+			paidIDs = append(paidIDs, a.ID)
 		}
+	}
+	locked := make(map[uint]bool)
+	if len(paidIDs) == 0 {
+		return locked
+	}
 
-		// Sort by Score DESC
-		sort.Slice(scored, func(i, j int) bool {
-			return scored[i].Score > scored[j].Score
-		})
+	purchased := make(map[uint]bool)
+	if uid != 0 {
+		var ids []uint
+		database.DB.Model(&models.Purchase{}).Where("user_id = ? AND article_id IN ?", uid, paidIDs).Pluck("article_id", &ids)
+		for _, id := range ids {
+			purchased[id] = true
+		}
+	}
 
-		// Extract results
-		results := make([]models.Article, len(scored))
-		for i, s := range scored {
-			results[i] = s.Article
+	for i := range articles {
+		a := &articles[i]
+		if a.IsPaid && a.AuthorID != uid && !purchased[a.ID] {
+			a.Content = teaser(a.Content)
+			locked[a.ID] = true
 		}
+	}
+	return locked
+}
 
-		c.JSON(http.StatusOK, gin.H{"data": results})
+// SemanticSearchArticles finds articles whose content is close in meaning to
+// the query, using the RAG embeddings. Only articles the caller can read are
+// searched; should one turn paid in between, it only exposes its title and a
+// teaser, never the matching chunk.
+func SemanticSearchArticles(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query is required"})
+		return
+	}
+	if RagService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Semantic search is unavailable"})
 		return
 	}
 
-	// Default Logic (No Search)
-	var articles []models.Article
-	query := db
+	limit := defaultPageSize
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, maxPageSize)
+	}
 
-	// Filter by free/paid
-	if typeParam := c.Query("type"); typeParam == "free" {
-		query = query.Where("is_paid = ?", false)
-	} else if typeParam == "paid" {
-		query = query.Where("is_paid = ?", true)
+	var uid uint
+	if userID, exists := c.Get("userID"); exists {
+		uid = userID.(uint)
+	}
+	matches, err := RagService.SemanticSearch(c.Request.Context(), uid, q, limit)
+	if err != nil {
+		log.Printf("Semantic search failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Semantic search failed"})
+		return
 	}
 
-	// Sort
-	if sort := c.Query("sort"); sort == "rank" {
-		query = query.Order("bookmark_count desc")
-	} else {
-		query = query.Order("created_at desc")
+	ids := make([]uint, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ArticleID
+	}
+	var found []models.Article
+	if len(ids) > 0 {
+		if err := database.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username") // Omit password
+		}).Where("id IN ?", ids).Find(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Semantic search failed"})
+			return
+		}
+	}
+	locked := hideLockedContent(c, found)
+	byID := make(map[uint]models.Article, len(found))
+	for _, a := range found {
+		byID[a.ID] = a
 	}
 
-	if err := query.Find(&articles).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch articles"})
-		return
+	results := make([]gin.H, 0, len(matches))
+	for _, m := range matches {
+		article, ok := byID[m.ArticleID]
+		if !ok {
+			continue // Deleted after it was indexed
+		}
+		result := gin.H{
+			"id":         article.ID,
+			"title":      article.Title,
+			"author":     article.Author,
+			"tags":       article.Tags,
+			"is_paid":    article.IsPaid,
+			"price":      article.Price,
+			"currency":   priceCurrency(&article),
+			"created_at": article.CreatedAt,
+			"has_access": !locked[article.ID],
+			"distance":   m.Distance,
+		}
+		if locked[article.ID] {
+			result["snippet"] = html.EscapeString(article.Content) // Teaser
+		} else {
+			result["snippet"] = search.Snippet(m.Content, q, snippetLength)
+			result["chunk_index"] = m.ChunkIndex
+			result["matches"] = m.Matches
+		}
+		results = append(results, result)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": articles})
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+func teaser(content string) string {
+	runes := []rune(content)
+	if len(runes) <= teaserLength {
+		return content
+	}
+	return string(runes[:teaserLength]) + "…"
 }
 
 func updateRankings(articleID int, scoreDelta float64) {
@@ -197,7 +372,7 @@ func updateRankings(articleID int, scoreDelta float64) {
 		// Or just rely on the populate logic setting it.
 		// Let's set it to be safe.
 		if len(key) > 15 { // crude check for daily/monthly vs yearly
-			pipe.Expire(database.Ctx, key, 7*24*time.Hour) 
+			pipe.Expire(database.Ctx, key, 7*24*time.Hour)
 		}
 	}
 	pipe.Exec(database.Ctx)
@@ -219,9 +394,14 @@ func GetArticle(c *gin.Context) {
 	// Check access permissions
 	userID, exists := c.Get("userID")
 	hasAccess := !article.IsPaid
-	
+
 	if exists {
 		uid := userID.(uint)
+		if uid != article.AuthorID {
+			// Reading history for the personalized feed, once a day
+			view := models.ArticleView{UserID: uid, ArticleID: article.ID, Day: time.Now().UTC().Truncate(24 * time.Hour)}
+			database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&view)
+		}
 		if uid == article.AuthorID {
 			hasAccess = true
 		} else if article.IsPaid {
@@ -256,6 +436,15 @@ func DeleteArticle(c *gin.Context) {
 	}
 
 	database.DB.Delete(&article)
+	ArticleSearch.Remove(article.ID)
+
+	// Drop vectors and chunks so RAG no longer retrieves the deleted article
+	if RagService != nil {
+		if err := RagService.DeleteArticleVectors(c.Request.Context(), article.ID); err != nil {
+			log.Printf("Failed to delete vectors for article %d: %v", article.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Article deleted"})
 }
 
@@ -270,7 +459,7 @@ func BookmarkArticle(c *gin.Context) {
 		// Already bookmarked, remove it
 		database.DB.Delete(&bookmark)
 		database.DB.Model(&models.Article{Model: gorm.Model{ID: uint(articleID)}}).UpdateColumn("bookmark_count", gorm.Expr("bookmark_count - ?", 1))
-		
+
 		// Update Redis Rankings (Decrement)
 		go updateRankings(articleID, -1)
 
@@ -283,7 +472,7 @@ func BookmarkArticle(c *gin.Context) {
 		}
 		database.DB.Create(&newBookmark)
 		database.DB.Model(&models.Article{Model: gorm.Model{ID: uint(articleID)}}).UpdateColumn("bookmark_count", gorm.Expr("bookmark_count + ?", 1))
-		
+
 		// Update Redis Rankings (Increment)
 		go updateRankings(articleID, 1)
 
@@ -316,7 +505,7 @@ func GetUserBookmarks(c *gin.Context) {
 	articles := make([]models.Article, len(bookmarks))
 	for i, b := range bookmarks {
 		articles[i] = b.Article
-		// Ensure Author info is minimal if needed, but Preload("Article.Author") fetches full user. 
+		// Ensure Author info is minimal if needed, but Preload("Article.Author") fetches full user.
 		// We might want to sanitize it (remove password) but GORM struct usually handles JSON tag "-" for password.
 	}
 
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"coin-wave/database"
+	"coin-wave/models"
+	"coin-wave/rag"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateChatSessionInput struct {
+	Title string `json:"title"`
+}
+
+type ChatMessageInput struct {
+	Question string `json:"question" binding:"required"`
+	Scope    string `json:"scope"`
+}
+
+func CreateChatSession(c *gin.Context) {
+	var input CreateChatSessionInput
+	// Title is optional, so an empty body is fine
+	c.ShouldBindJSON(&input)
+
+	userID, _ := c.Get("userID")
+	session := models.ChatSession{
+		UserID: userID.(uint),
+		Title:  input.Title,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": session})
+}
+
+func GetChatSessions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var sessions []models.ChatSession
+	if err := database.DB.Where("user_id = ?", userID).Order("updated_at desc").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+func GetChatSession(c *gin.Context) {
+	session, ok := findChatSession(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Where("session_id = ?", session.ID).Order("id asc").Find(&session.Messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": session})
+}
+
+// ContinueChatSession asks a question in the context of the session's previous turns
+func ContinueChatSession(c *gin.Context) {
+	var input ChatMessageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !rag.IsValidScope(input.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope"})
+		return
+	}
+
+	session, ok := findChatSession(c)
+	if !ok {
+		return
+	}
+
+	if RagService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "RAG Service not initialized"})
+		return
+	}
+
+	// Load the most recent turns, oldest first
+	var recent []models.ChatMessage
+	database.DB.Where("session_id = ?", session.ID).Order("id desc").Limit(rag.HistoryWindow).Find(&recent)
+	history := make([]rag.Message, len(recent))
+	for i, m := range recent {
+		history[len(recent)-1-i] = rag.Message{Role: m.Role, Content: m.Content}
+	}
+
+	start := time.Now()
+	answer, citations, timings, err := RagService.Query(c.Request.Context(), session.UserID, input.Question, rag.QueryOptions{History: history, Scope: input.Scope})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "RAG Query failed: " + err.Error()})
+		return
+	}
+	timings["total_handler"] = time.Since(start).Seconds()
+
+	userMsg := models.ChatMessage{SessionID: session.ID, Role: "user", Content: input.Question}
+	assistantMsg := models.ChatMessage{SessionID: session.ID, Role: "assistant", Content: answer}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&userMsg).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&assistantMsg).Error; err != nil {
+			return err
+		}
+
+		// Name untitled sessions after their first question and bump updated_at
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if session.Title == "" {
+			title := []rune(input.Question)
+			if len(title) > 50 {
+				title = title[:50]
+			}
+			updates["title"] = string(title)
+		}
+		return tx.Model(session).Updates(updates).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"answer":     answer,
+		"sources":    rag.SourceTexts(citations),
+		"citations":  citations,
+		"timings":    timings,
+		"session_id": session.ID,
+		"messages":   []models.ChatMessage{userMsg, assistantMsg},
+	})
+}
+
+func DeleteChatSession(c *gin.Context) {
+	session, ok := findChatSession(c)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", session.ID).Delete(&models.ChatMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(session).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session deleted"})
+}
+
+// findChatSession loads the session from the :id param and checks it belongs to the caller.
+// It writes the error response itself and returns false on failure.
+func findChatSession(c *gin.Context) (*models.ChatSession, bool) {
+	userID, _ := c.Get("userID")
+
+	var session models.ChatSession
+	if err := database.DB.First(&session, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return nil, false
+	}
+	if session.UserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
+		return nil, false
+	}
+	return &session, true
+}
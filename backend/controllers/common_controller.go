@@ -3,6 +3,7 @@ package controllers
 import (
 	"coin-wave/database"
 	"coin-wave/models"
+	"coin-wave/rates"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,16 +12,31 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// GetExchangeRate returns the latest quotes. "data" keeps the original shape:
+// fiat currencies in units per USD, crypto currencies in USD per unit.
 func GetExchangeRate(c *gin.Context) {
-	// Mock data
-	rates := map[string]float64{
-		"USD": 1.0,
-		"CNY": 7.25,
-		"EUR": 0.92,
-		"BTC": 65000.0,
-		"ETH": 3500.0,
+	quotes := RateService.Quotes(c.Request.Context())
+	if len(quotes) <= 1 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Exchange rates unavailable"})
+		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": rates})
+
+	// Report the oldest quote so clients can tell how fresh the whole set is
+	var updatedAt time.Time
+	stale := false
+	for _, q := range quotes[1:] {
+		if updatedAt.IsZero() || q.UpdatedAt.Before(updatedAt) {
+			updatedAt = q.UpdatedAt
+		}
+		stale = stale || q.Stale
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       rates.Legacy(quotes),
+		"quotes":     quotes,
+		"updated_at": updatedAt,
+		"stale":      stale,
+	})
 }
 
 func GetRankings(c *gin.Context) {
@@ -0,0 +1,565 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"coin-wave/database"
+	"coin-wave/models"
+	"coin-wave/rag"
+	"coin-wave/search"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Feed settings
+const (
+	feedHistoryWindow    = 90 * 24 * time.Hour // Signals older than this are ignored
+	feedInterestHalfLife = 14 * 24 * time.Hour // A signal's weight halves every 14 days
+	feedMaxViews         = 200
+	feedMaxSeeds         = 30 // Strongest signals used for the embedding profile
+	feedPoolSize         = 100
+	feedPurgeBatch       = 10000 // Article views deleted per statement
+
+	defaultFreshnessDays = 14 // Article age at which the freshness boost halves
+	defaultMaxPerAuthor  = 2  // Articles per author before they are pushed down
+	feedAuthorPenalty    = 0.5
+
+	// Signal weights
+	weightView     = 1.0
+	weightBookmark = 3.0
+	weightPurchase = 4.0
+	weightFollow   = 5.0
+
+	// Blend of candidate sources
+	blendSimilar = 1.0
+	blendTag     = 0.6
+	blendTrend   = 0.4
+)
+
+// Feed reasons
+const (
+	reasonSimilar  = "similar"
+	reasonTag      = "tag"
+	reasonTrending = "trending"
+	reasonLatest   = "latest"
+)
+
+type feedCandidate struct {
+	Article models.Article
+	Similar float64 // Cosine similarity to the interest profile
+	SeedID  uint    // Seed article behind Similar
+	Tag     float64 // Normalised affinity of the best matching tag
+	TagName string
+	Trend   float64 // Normalised trending score
+	Score   float64
+	Reason  string
+	Label   string
+}
+
+// GetFeed returns a personalized, paginated article feed. Candidates come from
+// articles similar to what the user bookmarked, bought and read, from tags
+// they follow or read about, from trending articles and from the newest ones.
+// Query: limit, cursor, freshness_days and max_per_author.
+func GetFeed(c *gin.Context) {
+	uid := c.MustGet("userID").(uint)
+
+	limit := defaultPageSize
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, maxPageSize)
+	}
+	freshness := float64(defaultFreshnessDays)
+	if v, err := strconv.ParseFloat(c.Query("freshness_days"), 64); err == nil && v > 0 {
+		freshness = v
+	}
+	maxPerAuthor := defaultMaxPerAuthor
+	if v, err := strconv.Atoi(c.Query("max_per_author")); err == nil && v > 0 {
+		maxPerAuthor = v
+	}
+	var cursor *search.Cursor
+	if v := c.Query("cursor"); v != "" {
+		cur, err := search.DecodeCursor(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		cursor = &cur
+	}
+
+	seeds, seen, err := interestSeeds(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+	tagAffinity, err := tagInterests(uid, seeds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+
+	candidates, err := feedCandidates(uid, seeds, seen, tagAffinity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+	ranked := rankFeed(candidates, tagAffinity, freshness, maxPerAuthor)
+
+	// Page after the cursor
+	page := make([]*feedCandidate, 0, limit+1)
+	for _, fc := range ranked {
+		if cursor != nil && !cursor.After(fc.Score, fc.Article.ID) {
+			continue
+		}
+		page = append(page, fc)
+		if len(page) > limit {
+			break
+		}
+	}
+	nextCursor := ""
+	if len(page) > limit {
+		page = page[:limit]
+		last := page[limit-1]
+		nextCursor = search.Cursor{Score: last.Score, ID: last.Article.ID}.Encode()
+	}
+
+	// Only the page needs contents
+	ids := make([]uint, len(page))
+	for i, fc := range page {
+		ids[i] = fc.Article.ID
+	}
+	var articles []models.Article
+	if len(ids) > 0 {
+		database.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username") // Omit password
+		}).Where("id IN ?", ids).Find(&articles)
+	}
+	hideLockedContent(c, articles)
+	byID := make(map[uint]models.Article, len(articles))
+	for _, a := range articles {
+		byID[a.ID] = a
+	}
+
+	items := make([]gin.H, 0, len(page))
+	for _, fc := range page {
+		a, ok := byID[fc.Article.ID]
+		if !ok {
+			continue
+		}
+		items = append(items, gin.H{
+			"article":      a,
+			"reason":       fc.Reason,
+			"reason_label": fc.Label,
+			"score":        fc.Score,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items, "next_cursor": nextCursor})
+}
+
+// interestSeeds weighs the articles a user bookmarked, bought and viewed,
+// decayed by age. seen holds every article with a signal, which the feed skips.
+func interestSeeds(uid uint) (seeds map[uint]float64, seen map[uint]bool, err error) {
+	seeds = make(map[uint]float64)
+	seen = make(map[uint]bool)
+	since := time.Now().Add(-feedHistoryWindow)
+
+	type signal struct {
+		ArticleID uint
+		CreatedAt time.Time
+	}
+	sources := []struct {
+		query  *gorm.DB
+		weight float64
+	}{
+		{database.DB.Model(&models.Bookmark{}).Where("user_id = ?", uid), weightBookmark},
+		{database.DB.Model(&models.Purchase{}).Where("user_id = ?", uid), weightPurchase},
+		{database.DB.Model(&models.ArticleView{}).Where("user_id = ? AND created_at > ?", uid, since).
+			Order("id desc").Limit(feedMaxViews), weightView},
+	}
+	for _, src := range sources {
+		var signals []signal
+		if err := src.query.Select("article_id, created_at").Find(&signals).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, s := range signals {
+			seen[s.ArticleID] = true
+			if s.CreatedAt.Before(since) {
+				continue // Still seen, but no longer an interest
+			}
+			seeds[s.ArticleID] += src.weight * decay(time.Since(s.CreatedAt), feedInterestHalfLife)
+		}
+	}
+
+	// Keep the strongest seeds so the profile stays focused
+	if len(seeds) > feedMaxSeeds {
+		type seed struct {
+			id     uint
+			weight float64
+		}
+		all := make([]seed, 0, len(seeds))
+		for id, w := range seeds {
+			all = append(all, seed{id, w})
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].weight > all[j].weight })
+		seeds = make(map[uint]float64, feedMaxSeeds)
+		for _, s := range all[:feedMaxSeeds] {
+			seeds[s.id] = s.weight
+		}
+	}
+	return seeds, seen, nil
+}
+
+// tagInterests scores lowercase tags from the seed articles and followed tags,
+// normalised so the strongest tag scores 1.
+func tagInterests(uid uint, seeds map[uint]float64) (map[string]float64, error) {
+	affinity := make(map[string]float64)
+
+	if len(seeds) > 0 {
+		ids := make([]uint, 0, len(seeds))
+		for id := range seeds {
+			ids = append(ids, id)
+		}
+		var seedArticles []models.Article
+		if err := database.DB.Select("id, tags").Where("id IN ?", ids).Find(&seedArticles).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range seedArticles {
+			for _, t := range search.SplitTags(a.Tags) {
+				affinity[strings.ToLower(t)] += seeds[a.ID]
+			}
+		}
+	}
+
+	var follows []models.TagFollow
+	if err := database.DB.Where("user_id = ?", uid).Find(&follows).Error; err != nil {
+		return nil, err
+	}
+	for _, f := range follows {
+		affinity[strings.ToLower(f.Tag)] += weightFollow
+	}
+
+	top := 0.0
+	for _, w := range affinity {
+		top = math.Max(top, w)
+	}
+	for t, w := range affinity {
+		affinity[t] = w / top
+	}
+	return affinity, nil
+}
+
+// feedCandidates gathers articles from every source, excluding the user's own
+// and already seen articles.
+func feedCandidates(uid uint, seeds map[uint]float64, seen map[uint]bool, tagAffinity map[string]float64) (map[uint]*feedCandidate, error) {
+	similar := make(map[uint]rag.ProfileMatch)
+	if RagService != nil {
+		matches, err := RagService.SimilarToProfile(seeds, seen, feedPoolSize)
+		if err != nil {
+			log.Printf("Feed: similar articles failed for user %d: %v", uid, err)
+		}
+		for _, m := range matches {
+			similar[m.ArticleID] = m
+		}
+	}
+	trending := trendingScores()
+
+	ids := make(map[uint]bool)
+	for id := range similar {
+		ids[id] = true
+	}
+	for id := range trending {
+		ids[id] = true
+	}
+
+	listColumns := "id, created_at, title, author_id, tags, is_paid, price, view_count, bookmark_count"
+	var articles []models.Article
+	pool := database.DB.Select(listColumns).Where("author_id <> ?", uid).Session(&gorm.Session{})
+
+	// Newest articles, which also covers users without any signal yet
+	var latest []models.Article
+	if err := pool.Order("id desc").Limit(feedPoolSize).Find(&latest).Error; err != nil {
+		return nil, err
+	}
+	articles = append(articles, latest...)
+
+	// Recent articles with the user's strongest tags
+	if tags := topTags(tagAffinity, 5); len(tags) > 0 {
+		// Match whole tags: ",btc," in ",wbtc,btc-news," is not a substring
+		// match, and spaces around commas are dropped on both sides
+		conds := make([]string, len(tags))
+		args := make([]interface{}, len(tags))
+		for i, t := range tags {
+			conds[i] = "CONCAT(',', REPLACE(LOWER(tags), ' ', ''), ',') LIKE ?"
+			args[i] = "%," + likeEscaper.Replace(strings.ReplaceAll(t, " ", "")) + ",%"
+		}
+		var tagged []models.Article
+		if err := pool.
+			Where("created_at > ?", time.Now().Add(-feedHistoryWindow)).
+			Where(strings.Join(conds, " OR "), args...).
+			Order("id desc").Limit(feedPoolSize).Find(&tagged).Error; err != nil {
+			return nil, err
+		}
+		// Dropping spaces can join two tags into one ("bit coin", "bitcoin"),
+		// so check again on the split tags
+		wanted := make(map[string]bool, len(tags))
+		for _, t := range tags {
+			wanted[t] = true
+		}
+		for _, a := range tagged {
+			for _, t := range search.SplitTags(a.Tags) {
+				if wanted[strings.ToLower(t)] {
+					articles = append(articles, a)
+					break
+				}
+			}
+		}
+	}
+
+	if len(ids) > 0 {
+		idList := make([]uint, 0, len(ids))
+		for id := range ids {
+			idList = append(idList, id)
+		}
+		var found []models.Article
+		if err := pool.Where("id IN ?", idList).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		articles = append(articles, found...)
+	}
+
+	candidates := make(map[uint]*feedCandidate)
+	for _, a := range articles {
+		if seen[a.ID] || candidates[a.ID] != nil {
+			continue
+		}
+		fc := &feedCandidate{Article: a, Trend: trending[a.ID]}
+		if m, ok := similar[a.ID]; ok {
+			fc.Similar = math.Max(m.Similarity, 0)
+			fc.SeedID = m.SeedID
+		}
+		candidates[a.ID] = fc
+	}
+	return candidates, nil
+}
+
+// trendingScores reads today's ranking from Redis, falling back to the most
+// bookmarked articles of the past week. Scores are normalised to 0-1.
+func trendingScores() map[uint]float64 {
+	scores := make(map[uint]float64)
+
+	key := "rankings:daily:" + time.Now().Format("2006-01-02")
+	vals, err := database.RDB.ZRevRangeWithScores(database.Ctx, key, 0, 49).Result()
+	if err == nil && len(vals) > 0 {
+		top := math.Max(vals[0].Score, 1)
+		for _, v := range vals {
+			member, _ := v.Member.(string)
+			if id, err := strconv.ParseUint(member, 10, 64); err == nil && v.Score > 0 {
+				scores[uint(id)] = v.Score / top
+			}
+		}
+		return scores
+	}
+
+	var ids []uint
+	database.DB.Model(&models.Article{}).
+		Where("created_at > ?", time.Now().AddDate(0, 0, -7)).
+		Order("bookmark_count desc, view_count desc").
+		Limit(50).Pluck("id", &ids)
+	for i, id := range ids {
+		scores[id] = 1 - float64(i)/float64(len(ids))
+	}
+	return scores
+}
+
+// rankFeed blends the candidate signals, boosts fresh articles, pushes down
+// authors beyond maxPerAuthor, and labels each item with its main reason.
+func rankFeed(candidates map[uint]*feedCandidate, tagAffinity map[string]float64, freshnessDays float64, maxPerAuthor int) []*feedCandidate {
+	ranked := make([]*feedCandidate, 0, len(candidates))
+	seedTitles := make(map[uint]string)
+
+	for _, fc := range candidates {
+		for _, t := range search.SplitTags(fc.Article.Tags) {
+			if w := tagAffinity[strings.ToLower(t)]; w > fc.Tag {
+				fc.Tag, fc.TagName = w, t
+			}
+		}
+
+		parts := map[string]float64{
+			reasonSimilar:  blendSimilar * fc.Similar,
+			reasonTag:      blendTag * fc.Tag,
+			reasonTrending: blendTrend * fc.Trend,
+		}
+		fc.Reason, fc.Score = reasonLatest, 0
+		for _, reason := range []string{reasonSimilar, reasonTag, reasonTrending} {
+			fc.Score += parts[reason]
+			if parts[reason] > parts[fc.Reason] {
+				fc.Reason = reason
+			}
+		}
+
+		// Fresh articles keep their full score, old ones keep half
+		age := time.Since(fc.Article.CreatedAt)
+		fresh := decay(age, time.Duration(freshnessDays*float64(24*time.Hour)))
+		fc.Score = (fc.Score + 0.1*fresh) * (0.5 + 0.5*fresh)
+
+		if fc.Reason == reasonSimilar {
+			seedTitles[fc.SeedID] = ""
+		}
+		ranked = append(ranked, fc)
+	}
+
+	if len(seedTitles) > 0 {
+		ids := make([]uint, 0, len(seedTitles))
+		for id := range seedTitles {
+			ids = append(ids, id)
+		}
+		var seedArticles []models.Article
+		database.DB.Unscoped().Select("id, title").Where("id IN ?", ids).Find(&seedArticles)
+		for _, a := range seedArticles {
+			seedTitles[a.ID] = a.Title
+		}
+	}
+	for _, fc := range ranked {
+		switch fc.Reason {
+		case reasonSimilar:
+			fc.Label = fmt.Sprintf("Because you read \"%s\"", seedTitles[fc.SeedID])
+		case reasonTag:
+			fc.Label = "More on #" + fc.TagName
+		case reasonTrending:
+			fc.Label = "Trending today"
+		default:
+			fc.Label = "New on Coin Wave"
+		}
+	}
+
+	byScore := func() {
+		sort.Slice(ranked, func(i, j int) bool {
+			if ranked[i].Score != ranked[j].Score {
+				return ranked[i].Score > ranked[j].Score
+			}
+			return ranked[i].Article.ID > ranked[j].Article.ID
+		})
+	}
+	byScore()
+
+	// Diversity: every article of an author beyond maxPerAuthor halves again
+	perAuthor := make(map[uint]int)
+	for _, fc := range ranked {
+		n := perAuthor[fc.Article.AuthorID]
+		if n >= maxPerAuthor {
+			fc.Score *= math.Pow(feedAuthorPenalty, float64(n-maxPerAuthor+1))
+		}
+		perAuthor[fc.Article.AuthorID] = n + 1
+	}
+	byScore()
+	return ranked
+}
+
+// likeEscaper escapes the LIKE wildcards in a pattern fragment.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func topTags(affinity map[string]float64, n int) []string {
+	tags := make([]string, 0, len(affinity))
+	for t := range affinity {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if affinity[tags[i]] != affinity[tags[j]] {
+			return affinity[tags[i]] > affinity[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if len(tags) > n {
+		tags = tags[:n]
+	}
+	return tags
+}
+
+// decay halves every halfLife.
+func decay(age, halfLife time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, age.Hours()/halfLife.Hours())
+}
+
+type FollowTagInput struct {
+	Tag string `json:"tag" binding:"required"`
+}
+
+func GetFollowedTags(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var follows []models.TagFollow
+	database.DB.Where("user_id = ?", userID).Order("tag asc").Find(&follows)
+	c.JSON(http.StatusOK, gin.H{"data": follows})
+}
+
+func FollowTag(c *gin.Context) {
+	var input FollowTagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tag := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input.Tag), "#"))
+	if tag == "" || len(tag) > 64 || strings.Contains(tag, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	var follow models.TagFollow
+	err := database.DB.Where("user_id = ? AND tag = ?", uid, tag).First(&follow).Error
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{"data": follow})
+		return
+	}
+	follow = models.TagFollow{UserID: uid, Tag: tag}
+	if err := database.DB.Create(&follow).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow tag"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": follow})
+}
+
+func UnfollowTag(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	// Hard delete so the tag can be followed again under the unique index
+	database.DB.Unscoped().Where("user_id = ? AND tag = ?", userID, c.Param("tag")).Delete(&models.TagFollow{})
+	c.JSON(http.StatusOK, gin.H{"message": "Tag unfollowed"})
+}
+
+// PurgeArticleViews deletes the article views older than the feed's history
+// window every hour until ctx is cancelled. Rows go in batches so the table
+// is never locked for long.
+func PurgeArticleViews(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := time.Now().UTC().Add(-feedHistoryWindow).Truncate(24 * time.Hour)
+			for ctx.Err() == nil {
+				res := database.DB.Unscoped().Where("day < ?", cutoff).Limit(feedPurgeBatch).Delete(&models.ArticleView{})
+				if res.Error != nil {
+					log.Printf("Failed to purge article views: %v", res.Error)
+					break
+				}
+				if res.RowsAffected < feedPurgeBatch {
+					break
+				}
+			}
+		}
+	}
+}
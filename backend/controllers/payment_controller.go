@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"coin-wave/database"
+	"coin-wave/models"
+	"coin-wave/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxWebhookBody = 1 << 20
+
+// GetDepositIntent returns one of the user's deposits, for clients polling
+// for the payment to go through.
+func GetDepositIntent(c *gin.Context) {
+	if !requirePayments(c) {
+		return
+	}
+	userID, _ := c.Get("userID")
+	var intent models.DepositIntent
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&intent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deposit not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": intent})
+}
+
+// PaymentWebhook receives payment callbacks from the provider named in the
+// path. It is not authenticated, the provider's signature is checked instead.
+func PaymentWebhook(c *gin.Context) {
+	if !requirePayments(c) {
+		return
+	}
+	if c.Param("provider") != PaymentService.Provider().Name() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+	handlePaymentEvent(c, body, c.Request.Header)
+}
+
+// CompleteMockPayment settles one of the user's deposits as paid, or declined
+// with {"status": "failed"}, through a signed webhook from the mock provider.
+// It is only routed when payments.MockEnabled is set.
+func CompleteMockPayment(c *gin.Context) {
+	if !requirePayments(c) {
+		return
+	}
+	mock, ok := PaymentService.Provider().(*payments.MockProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mock payments are disabled"})
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status == "" {
+		input.Status = payments.StatusSucceeded
+	}
+
+	userID, _ := c.Get("userID")
+	var intent models.DepositIntent
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&intent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deposit not found"})
+		return
+	}
+
+	body, header, err := mock.Complete(intent.Reference, input.Status)
+	if err != nil {
+		if errors.Is(err, payments.ErrUnknownIntent) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment is no longer open"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	handlePaymentEvent(c, body, header)
+}
+
+func handlePaymentEvent(c *gin.Context, body []byte, header http.Header) {
+	intent, err := PaymentService.HandleWebhook(body, header)
+	if err != nil {
+		switch {
+		case errors.Is(err, payments.ErrInvalidSignature):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature"})
+		case errors.Is(err, payments.ErrUnknownIntent):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deposit not found"})
+		case errors.Is(err, payments.ErrAmountMismatch):
+			// Acknowledged, retrying would not change the outcome
+			c.JSON(http.StatusOK, gin.H{"data": intent})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": intent})
+}
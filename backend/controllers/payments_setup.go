@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+
+	"coin-wave/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentService is nil when no payment provider is configured.
+var PaymentService *payments.Service
+
+// InitPayments sets the service deposits are paid through.
+func InitPayments(service *payments.Service) {
+	PaymentService = service
+}
+
+// requirePayments answers 503 and returns false when deposits are disabled.
+func requirePayments(c *gin.Context) bool {
+	if PaymentService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Deposits are not available"})
+		return false
+	}
+	return true
+}
@@ -3,6 +3,8 @@ package controllers
 import (
 	"coin-wave/database"
 	"coin-wave/models"
+	"coin-wave/rag"
+	"log"
 	"net/http"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 
 type RagQueryInput struct {
 	Question string `json:"question" binding:"required"`
+	Scope    string `json:"scope"` // own (default), purchased, bookmarked, public_free, all_accessible
 }
 
 func RagQuery(c *gin.Context) {
@@ -27,13 +30,18 @@ func RagQuery(c *gin.Context) {
 	}
 	uid := userID.(uint)
 
+	if !rag.IsValidScope(input.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope"})
+		return
+	}
+
 	if RagService == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "RAG Service not initialized"})
 		return
 	}
 
 	start := time.Now()
-	answer, sources, timings, err := RagService.Query(c.Request.Context(), uid, input.Question)
+	answer, citations, timings, err := RagService.Query(c.Request.Context(), uid, input.Question, rag.QueryOptions{Scope: input.Scope})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "RAG Query failed: " + err.Error()})
 		return
@@ -46,9 +54,71 @@ func RagQuery(c *gin.Context) {
 	timings["total_handler"] = time.Since(start).Seconds()
 
 	c.JSON(http.StatusOK, gin.H{
-		"answer":  answer,
-		"sources": sources,
-		"timings": timings,
+		"answer":    answer,
+		"sources":   rag.SourceTexts(citations),
+		"citations": citations,
+		"timings":   timings,
+	})
+}
+
+// RagQueryStream answers like RagQuery but streams Server-Sent Events:
+// "sources" after retrieval, "delta" per answer token, then "done" with the
+// full answer and timings, or "error". Closing the connection cancels the LLM call.
+func RagQueryStream(c *gin.Context) {
+	var input RagQueryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid := userID.(uint)
+
+	if !rag.IsValidScope(input.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope"})
+		return
+	}
+
+	if RagService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "RAG Service not initialized"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+
+	ctx := c.Request.Context()
+	emit := func(event string, data interface{}) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+		return nil
+	}
+
+	start := time.Now()
+	answer, citations, timings, err := RagService.QueryStream(ctx, uid, input.Question, rag.QueryOptions{Scope: input.Scope}, emit)
+	if err != nil {
+		if ctx.Err() == nil {
+			emit("error", gin.H{"error": "RAG Query failed: " + err.Error()})
+		}
+		return
+	}
+
+	timings["total_handler"] = time.Since(start).Seconds()
+
+	emit("done", gin.H{
+		"answer":    answer,
+		"sources":   rag.SourceTexts(citations),
+		"citations": citations,
+		"timings":   timings,
 	})
 }
 
@@ -68,13 +138,110 @@ func ReIndexArticle(c *gin.Context) {
 		return
 	}
 
-	// Reset progress
-	article.VectorProgress = 0
-	database.DB.Save(&article)
-
-	if RagWorker != nil {
-		RagWorker.Run(c.Request.Context(), article.ID)
+	if RagQueue != nil {
+		if err := RagQueue.Enqueue(article.ID); err != nil {
+			log.Printf("Failed to enqueue ingestion for article %d: %v", article.ID, err)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Re-indexing triggered"})
 }
+
+// GetIndexStatus returns the ingestion state of an article and its latest job
+func GetIndexStatus(c *gin.Context) {
+	article, ok := findOwnArticle(c)
+	if !ok {
+		return
+	}
+
+	resp := gin.H{"data": indexStatusEvent(article)}
+	if RagQueue != nil {
+		if job, err := RagQueue.LatestJob(article.ID); err == nil && job != nil {
+			resp["job"] = job
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// StreamIndexStatus pushes ingestion progress as Server-Sent Events ("progress")
+// until the article reaches completed/failed or the client disconnects.
+func StreamIndexStatus(c *gin.Context) {
+	article, ok := findOwnArticle(c)
+	if !ok {
+		return
+	}
+
+	if RagService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "RAG Service not initialized"})
+		return
+	}
+
+	// Subscribe before reading the snapshot so no transition is missed in between
+	events, unsubscribe := RagService.SubscribeProgress(article.ID)
+	defer unsubscribe()
+	if err := database.DB.Select("id", "vector_status", "vector_stage", "vector_progress", "vector_error", "updated_at").
+		First(article, article.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	current := indexStatusEvent(article)
+	c.SSEvent("progress", current)
+	c.Writer.Flush()
+	if current.Terminal() {
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		case ev := <-events:
+			c.SSEvent("progress", ev)
+			c.Writer.Flush()
+			if ev.Terminal() {
+				return
+			}
+		}
+	}
+}
+
+func indexStatusEvent(article *models.Article) rag.ProgressEvent {
+	return rag.ProgressEvent{
+		ArticleID: article.ID,
+		Status:    article.VectorStatus,
+		Stage:     article.VectorStage,
+		Progress:  article.VectorProgress,
+		Error:     article.VectorError,
+		At:        article.UpdatedAt,
+	}
+}
+
+// findOwnArticle loads the article from the :id param and checks the caller wrote it.
+// It writes the error response itself and returns false on failure.
+func findOwnArticle(c *gin.Context) (*models.Article, bool) {
+	userID, _ := c.Get("userID")
+
+	var article models.Article
+	if err := database.DB.First(&article, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return nil, false
+	}
+	if article.AuthorID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
+		return nil, false
+	}
+	return &article, true
+}
@@ -1,13 +1,76 @@
 package controllers
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"coin-wave/database"
 	"coin-wave/rag"
 )
 
-var RagWorker *rag.IngestionWorker
+var RagQueue *rag.JobQueue
 var RagService *rag.RagService
 
-func InitRag(worker *rag.IngestionWorker, service *rag.RagService) {
-	RagWorker = worker
+// ragIndexChannel carries "<instance>:<article ID>" whenever a replica
+// re-indexes or deletes an article, so the others refresh their in-memory
+// keyword index and article vectors.
+const ragIndexChannel = "rag:index-changed"
+
+// ragInstance tells this replica's own index changes apart from the others'.
+var ragInstance = newInstanceID()
+
+func InitRag(ctx context.Context, queue *rag.JobQueue, service *rag.RagService) {
+	RagQueue = queue
 	RagService = service
+	if service != nil {
+		service.OnIndexChange(invalidateRelated)
+		service.OnIndexChange(publishIndexChange)
+		go followIndexChanges(ctx, service)
+	}
+}
+
+func publishIndexChange(articleID uint) {
+	msg := fmt.Sprintf("%s:%d", ragInstance, articleID)
+	if err := database.RDB.Publish(database.Ctx, ragIndexChannel, msg).Err(); err != nil {
+		log.Printf("Failed to publish index change of article %d: %v", articleID, err)
+	}
+}
+
+// followIndexChanges reloads the articles other replicas re-index or delete
+// until ctx is cancelled. Changes published while Redis is unreachable are
+// missed: the keyword index catches up on the next restart, article vectors
+// when they are next reloaded, see rag.RelatedVectorsTTL.
+func followIndexChanges(ctx context.Context, service *rag.RagService) {
+	sub := database.RDB.Subscribe(ctx, ragIndexChannel)
+	defer sub.Close()
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			instance, id, _ := strings.Cut(msg.Payload, ":")
+			articleID, err := strconv.ParseUint(id, 10, 64)
+			if instance == ragInstance || err != nil {
+				continue
+			}
+			if err := service.ReloadArticle(uint(articleID)); err != nil {
+				log.Printf("Failed to reload article %d after an index change: %v", articleID, err)
+			}
+		}
+	}
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
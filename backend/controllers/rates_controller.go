@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"coin-wave/money"
+	"coin-wave/rates"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultHistoryCandles = 100
+
+// GetRateHistory returns OHLC candles of a currency's USD price. Query params:
+// interval (default 1h), from and to (RFC 3339 or unix seconds, default the
+// last 100 candles) and format=csv.
+func GetRateHistory(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if !rates.Known(symbol) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown symbol"})
+		return
+	}
+
+	intervalName := c.DefaultQuery("interval", "1h")
+	interval, ok := rates.Intervals[intervalName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval"})
+		return
+	}
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to"})
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultHistoryCandles * interval)
+	if v := c.Query("from"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from"})
+			return
+		}
+		from = t
+	}
+	from = from.Truncate(interval)
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if to.Sub(from) > time.Duration(rates.MaxCandles)*interval {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range too large, at most %d candles per request", rates.MaxCandles)})
+		return
+	}
+
+	candles, err := RateService.History(symbol, interval, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rate history"})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, symbol, intervalName))
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"time", "open", "high", "low", "close", "samples"})
+		for _, k := range candles {
+			w.Write([]string{
+				k.Time.UTC().Format(time.RFC3339),
+				formatPrice(k.Open),
+				formatPrice(k.High),
+				formatPrice(k.Low),
+				formatPrice(k.Close),
+				strconv.Itoa(k.Samples),
+			})
+		}
+		w.Flush()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     candles,
+		"symbol":   symbol,
+		"interval": intervalName,
+		"from":     from,
+		"to":       to,
+	})
+}
+
+// parseTime accepts RFC 3339 timestamps, unix seconds and dates, the latter
+// as local midnight.
+func parseTime(v string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func formatPrice(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// ConvertCurrency converts amount (default 1) between two currencies at the
+// current rate.
+func ConvertCurrency(c *gin.Context) {
+	from := strings.ToUpper(c.Query("from"))
+	to := strings.ToUpper(c.Query("to"))
+	amount := money.NewFromInt(1)
+	if v := c.Query("amount"); v != "" {
+		a, err := money.Parse(v)
+		if err != nil || a.Sign() < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+			return
+		}
+		amount = a
+	}
+
+	conv, err := RateService.Convert(c.Request.Context(), from, to)
+	if err != nil {
+		if errors.Is(err, rates.ErrUnknownCurrency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown currency"})
+		} else {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Exchange rate unavailable"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"from":       conv.From,
+		"to":         conv.To,
+		"amount":     amount,
+		"rate":       conv.Rate,
+		"result":     amount.Mul(conv.Rate),
+		"updated_at": conv.UpdatedAt,
+		"stale":      conv.Stale,
+	}})
+}
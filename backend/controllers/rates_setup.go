@@ -0,0 +1,10 @@
+package controllers
+
+import "coin-wave/rates"
+
+var RateService *rates.Service
+
+// InitRates sets the service behind GetExchangeRate.
+func InitRates(service *rates.Service) {
+	RateService = service
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"coin-wave/database"
+	"coin-wave/models"
+	"coin-wave/rag"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Related articles settings
+const (
+	defaultRelatedLimit = 5
+	maxRelatedLimit     = 20
+	relatedCacheTTL     = time.Hour
+	// Bumped whenever any article is re-indexed or deleted: a new vector can
+	// change the neighbours of every other article, so all cached lists expire
+	relatedVersionKey = "related:version"
+)
+
+// GetRelatedArticles returns the articles most similar to an article, based on
+// the mean of its chunk embeddings. exclude_author=true hides the same author.
+// Each result says whether the caller, if signed in, can read it.
+func GetRelatedArticles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+	limit := defaultRelatedLimit
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, maxRelatedLimit)
+	}
+	excludeAuthor := c.Query("exclude_author") == "true"
+
+	var article models.Article
+	if err := database.DB.Select("id").First(&article, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return
+	}
+	if RagService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Recommendations are unavailable"})
+		return
+	}
+
+	related, err := cachedRelated(article.ID, limit, excludeAuthor)
+	if err != nil {
+		log.Printf("Failed to compute related articles for %d: %v", article.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch related articles"})
+		return
+	}
+
+	ids := make([]uint, len(related))
+	for i, r := range related {
+		ids[i] = r.ArticleID
+	}
+	var found []models.Article
+	if len(ids) > 0 {
+		database.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username") // Omit password
+		}).Select("id, created_at, title, author_id, tags, is_paid, price, view_count, bookmark_count").
+			Where("id IN ?", ids).Find(&found)
+	}
+	locked := hideLockedContent(c, found)
+	byID := make(map[uint]models.Article, len(found))
+	for _, a := range found {
+		byID[a.ID] = a
+	}
+
+	// List view only: no content, so paid articles leak nothing
+	results := make([]gin.H, 0, len(related))
+	for _, r := range related {
+		a, ok := byID[r.ArticleID]
+		if !ok {
+			continue
+		}
+		results = append(results, gin.H{
+			"id":             a.ID,
+			"title":          a.Title,
+			"author":         a.Author,
+			"tags":           a.Tags,
+			"is_paid":        a.IsPaid,
+			"price":          a.Price,
+			"view_count":     a.ViewCount,
+			"bookmark_count": a.BookmarkCount,
+			"created_at":     a.CreatedAt,
+			"similarity":     r.Similarity,
+			"has_access":     !locked[a.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+// cachedRelated serves related articles from Redis, computing them on a miss.
+// Redis failures fall back to computing every time.
+func cachedRelated(articleID uint, limit int, excludeAuthor bool) ([]rag.RelatedArticle, error) {
+	version, err := database.RDB.Get(database.Ctx, relatedVersionKey).Int64()
+	cacheable := err == nil || errors.Is(err, redis.Nil)
+	key := fmt.Sprintf("related:v%d:%d:%d:%t", version, articleID, limit, excludeAuthor)
+
+	if cacheable {
+		if data, err := database.RDB.Get(database.Ctx, key).Bytes(); err == nil {
+			var related []rag.RelatedArticle
+			if json.Unmarshal(data, &related) == nil {
+				return related, nil
+			}
+		}
+	}
+
+	related, err := RagService.RelatedArticles(articleID, limit, excludeAuthor)
+	if err != nil {
+		return nil, err
+	}
+	if related == nil {
+		// Not indexed yet, don't cache so results show up once it is
+		return []rag.RelatedArticle{}, nil
+	}
+	if cacheable {
+		if data, err := json.Marshal(related); err == nil {
+			database.RDB.Set(database.Ctx, key, data, relatedCacheTTL)
+		}
+	}
+	return related, nil
+}
+
+// invalidateRelated expires every cached related list, see relatedVersionKey.
+func invalidateRelated(articleID uint) {
+	if err := database.RDB.Incr(database.Ctx, relatedVersionKey).Err(); err != nil {
+		log.Printf("Failed to invalidate related articles cache after article %d changed: %v", articleID, err)
+	}
+}
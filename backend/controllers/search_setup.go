@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+
+	"coin-wave/database"
+	"coin-wave/models"
+	"coin-wave/rates"
+	"coin-wave/search"
+
+	"gorm.io/gorm"
+)
+
+var ArticleSearch = search.NewArticleIndex()
+
+// InitSearch indexes every article for GetArticles. The index is kept up to
+// date by CreateArticle and DeleteArticle afterwards.
+func InitSearch() error {
+	var batch []models.Article
+	return database.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username")
+	}).FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			ArticleSearch.Add(articleDoc(&batch[i], batch[i].Author.Username))
+		}
+		return nil
+	}).Error
+}
+
+func articleDoc(article *models.Article, authorName string) search.ArticleDoc {
+	return search.ArticleDoc{
+		ID:         article.ID,
+		Title:      article.Title,
+		Content:    article.Content,
+		Tags:       article.Tags,
+		AuthorID:   article.AuthorID,
+		AuthorName: authorName,
+		IsPaid:     article.IsPaid,
+		Price:      article.Price.Float64(),
+		Currency:   article.PriceCurrency,
+	}
+}
+
+// articlePopularity loads the view and bookmark counts that boost search
+// results. They change on every view, so they are read from the database
+// rather than indexed.
+func articlePopularity(ids []uint) (map[uint]search.Popularity, error) {
+	out := make(map[uint]search.Popularity, len(ids))
+	for chunk := range slices.Chunk(ids, 1000) {
+		var rows []struct {
+			ID            uint
+			ViewCount     int
+			BookmarkCount int
+		}
+		err := database.DB.Model(&models.Article{}).Select("id, view_count, bookmark_count").Where("id IN ?", chunk).Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			out[r.ID] = search.Popularity{ViewCount: r.ViewCount, BookmarkCount: r.BookmarkCount}
+		}
+	}
+	return out, nil
+}
+
+// walletRates returns the number of wallet currency units per unit of each
+// currency with a quote, for bucketing prices in the wallet currency.
+func walletRates(ctx context.Context) map[string]float64 {
+	out := map[string]float64{rates.WalletCurrency: 1}
+	if RateService == nil {
+		return out
+	}
+	quotes := RateService.Quotes(ctx)
+	var wallet float64
+	for _, q := range quotes {
+		if q.Symbol == rates.WalletCurrency {
+			wallet = q.PriceUSD
+		}
+	}
+	if wallet <= 0 {
+		return out
+	}
+	for _, q := range quotes {
+		out[q.Symbol] = q.PriceUSD / wallet
+	}
+	return out
+}
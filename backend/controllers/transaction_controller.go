@@ -0,0 +1,369 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"coin-wave/database"
+	"coin-wave/ledger"
+	"coin-wave/models"
+	"coin-wave/money"
+	"coin-wave/rates"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const exportBatchSize = 500
+
+var transactionTypes = []string{"deposit", "purchase", "sale"}
+
+// walletTransaction is a wallet log with the balance right after it.
+type walletTransaction struct {
+	ID          uint          `json:"id"`
+	Type        string        `json:"type"`
+	Amount      money.Decimal `json:"amount"`
+	Currency    string        `json:"currency"`
+	Description string        `json:"description"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Balance     money.Decimal `gorm:"-" json:"balance"`
+	LaterTotal  money.Decimal `json:"-"` // Sum of the user's newer logs
+}
+
+// transactionFilter holds the type and date range query params shared by the
+// history and export endpoints.
+type transactionFilter struct {
+	types    []string
+	from, to time.Time
+}
+
+func parseTransactionFilter(c *gin.Context) (transactionFilter, error) {
+	var f transactionFilter
+	if v := c.Query("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(transactionTypes, t) {
+				return f, fmt.Errorf("Invalid type")
+			}
+			f.types = append(f.types, t)
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return f, fmt.Errorf("Invalid from")
+		}
+		f.from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return f, fmt.Errorf("Invalid to")
+		}
+		f.to = t
+	}
+	return f, nil
+}
+
+func (f transactionFilter) matches(t *walletTransaction) bool {
+	if len(f.types) > 0 && !slices.Contains(f.types, t.Type) {
+		return false
+	}
+	if !f.from.IsZero() && t.CreatedAt.Before(f.from) {
+		return false
+	}
+	return f.to.IsZero() || t.CreatedAt.Before(f.to)
+}
+
+// transactionQuery selects the user's wallet logs matching the filter, newest
+// first. The running total of newer logs is computed over all of them before
+// filtering, so balances stay right whatever the filter. Logs in another
+// currency than the wallet's, from before it was changed, are left out of
+// the total.
+func transactionQuery(userID uint, f transactionFilter) *gorm.DB {
+	inner := database.DB.Model(&models.WalletLog{}).
+		Select("id, type, amount, currency, description, created_at, "+
+			"COALESCE(SUM(CASE WHEN currency = ? THEN amount ELSE 0 END) OVER (ORDER BY id DESC ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING), 0) AS later_total",
+			rates.WalletCurrency).
+		Where("user_id = ?", userID)
+
+	query := database.DB.Table("(?) AS t", inner)
+	if len(f.types) > 0 {
+		query = query.Where("type IN ?", f.types)
+	}
+	if !f.from.IsZero() {
+		query = query.Where("created_at >= ?", f.from)
+	}
+	if !f.to.IsZero() {
+		query = query.Where("created_at < ?", f.to)
+	}
+	return query.Order("id desc")
+}
+
+// walletBalance returns the user's current balance from the ledger.
+func walletBalance(userID uint) (money.Decimal, error) {
+	var account models.LedgerAccount
+	err := database.DB.Where("user_id = ?", userID).Limit(1).Find(&account).Error
+	return ledger.FromMinor(account.Balance), err
+}
+
+// fillBalances sets each row's balance: the current balance less everything
+// that came after the row.
+func fillBalances(rows []walletTransaction, current money.Decimal) {
+	for i := range rows {
+		rows[i].Balance = current.Sub(rows[i].LaterTotal)
+	}
+}
+
+// GetWalletTransactions lists the wallet history newest first with the balance
+// after each row. Query params: type (comma separated deposit, purchase,
+// sale), from and to, limit and cursor (the next_cursor of the previous page).
+func GetWalletTransactions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := defaultPageSize
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, maxPageSize)
+	}
+
+	query := transactionQuery(uid, filter)
+	if v := c.Query("cursor"); v != "" {
+		before, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query = query.Where("id < ?", before)
+	}
+
+	var rows []walletTransaction
+	if err := query.Limit(limit + 1).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+	var nextCursor string
+	if len(rows) > limit {
+		rows = rows[:limit]
+		nextCursor = strconv.FormatUint(uint64(rows[limit-1].ID), 10)
+	}
+
+	balance, err := walletBalance(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+	fillBalances(rows, balance)
+	if rows == nil {
+		rows = []walletTransaction{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        rows,
+		"next_cursor": nextCursor,
+		"balance":     balance,
+		"currency":    rates.WalletCurrency,
+	})
+}
+
+// ExportWalletTransactions downloads every transaction matching the filters
+// of GetWalletTransactions, as CSV (default) or JSON with format=json.
+func ExportWalletTransactions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+		return
+	}
+	balance, err := walletBalance(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	filename := "transactions-" + time.Now().Format("20060102")
+	var write func(t *walletTransaction) error
+	var finish func() error
+	if format == "json" {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		fmt.Fprintf(c.Writer, `{"currency":%q,"data":[`, rates.WalletCurrency)
+		enc := json.NewEncoder(c.Writer)
+		first := true
+		write = func(t *walletTransaction) error {
+			if !first {
+				c.Writer.WriteString(",")
+			}
+			first = false
+			return enc.Encode(t)
+		}
+		finish = func() error {
+			_, err := c.Writer.WriteString("]}")
+			return err
+		}
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "time", "type", "amount", "currency", "balance", "description"})
+		write = func(t *walletTransaction) error {
+			return w.Write([]string{
+				strconv.FormatUint(uint64(t.ID), 10),
+				t.CreatedAt.Format(time.RFC3339),
+				t.Type,
+				t.Amount.String(),
+				t.Currency,
+				t.Balance.String(),
+				t.Description,
+			})
+		}
+		finish = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	}
+	c.Status(http.StatusOK)
+
+	// Walk the whole history newest first in id pages, carrying the balance
+	// from row to row, and write the matching rows as they are read. Headers
+	// are sent by then, so a failure can only cut the download short.
+	if err := walkWalletHistory(uid, balance, func(t *walletTransaction) error {
+		if !filter.matches(t) {
+			return nil
+		}
+		return write(t)
+	}); err != nil {
+		log.Printf("[Wallet] Export for user %d failed: %v", uid, err)
+		return
+	}
+	if err := finish(); err != nil {
+		log.Printf("[Wallet] Export for user %d failed: %v", uid, err)
+	}
+}
+
+// walkWalletHistory calls fn for each of the user's wallet logs, newest
+// first, with its balance set from current. Pages are plain id range scans.
+func walkWalletHistory(userID uint, current money.Decimal, fn func(t *walletTransaction) error) error {
+	balance := current
+	var before uint
+	for {
+		query := database.DB.Model(&models.WalletLog{}).
+			Select("id, type, amount, currency, description, created_at").
+			Where("user_id = ?", userID)
+		if before > 0 {
+			query = query.Where("id < ?", before)
+		}
+		var rows []walletTransaction
+		if err := query.Order("id desc").Limit(exportBatchSize).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for i := range rows {
+			rows[i].Balance = balance
+			if rows[i].Currency == rates.WalletCurrency {
+				balance = balance.Sub(rows[i].Amount)
+			}
+			if err := fn(&rows[i]); err != nil {
+				return err
+			}
+		}
+		if len(rows) < exportBatchSize {
+			return nil
+		}
+		before = rows[len(rows)-1].ID
+	}
+}
+
+// GetWalletStatement summarises one month (month=YYYY-MM, default the current
+// one): opening and closing balance and the totals deposited, spent and
+// earned, counting only logs in the wallet currency.
+func GetWalletStatement(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	if v := c.Query("month"); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month, use YYYY-MM"})
+			return
+		}
+		start = t
+	}
+	end := start.AddDate(0, 1, 0)
+
+	type typeTotal struct {
+		Type  string
+		Total money.Decimal
+		Count int
+	}
+	var totals []typeTotal
+	err := database.DB.Model(&models.WalletLog{}).
+		Select("type, COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Where("user_id = ? AND currency = ? AND created_at >= ? AND created_at < ?", uid, rates.WalletCurrency, start, end).
+		Group("type").Scan(&totals).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build statement"})
+		return
+	}
+
+	// Closing is the current balance less everything since the month ended
+	var after money.Decimal
+	err = database.DB.Model(&models.WalletLog{}).Select("COALESCE(SUM(amount), 0)").
+		Where("user_id = ? AND currency = ? AND created_at >= ?", uid, rates.WalletCurrency, end).Scan(&after).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build statement"})
+		return
+	}
+	balance, err := walletBalance(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	var deposited, spent, earned, net money.Decimal
+	count := 0
+	for _, t := range totals {
+		switch t.Type {
+		case "deposit":
+			deposited = deposited.Add(t.Total)
+		case "purchase":
+			spent = spent.Sub(t.Total) // Purchases are negative
+		case "sale":
+			earned = earned.Add(t.Total)
+		}
+		net = net.Add(t.Total)
+		count += t.Count
+	}
+	closing := balance.Sub(after)
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"month":           start.Format("2006-01"),
+		"currency":        rates.WalletCurrency,
+		"opening_balance": closing.Sub(net),
+		"closing_balance": closing,
+		"deposited":       deposited,
+		"spent":           spent,
+		"earned":          earned,
+		"transactions":    count,
+	}})
+}
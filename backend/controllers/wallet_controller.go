@@ -2,61 +2,177 @@ package controllers
 
 import (
 	"coin-wave/database"
+	"coin-wave/ledger"
 	"coin-wave/models"
+	"coin-wave/money"
+	"coin-wave/rates"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DepositInput struct {
-	Amount float64 `json:"amount" binding:"required,gt=0"`
+	Amount money.Decimal `json:"amount"` // In the wallet currency
 }
 
+// Deposit opens a deposit intent for the amount, to be paid with the payment
+// provider through its redirect URL or QR payload.
 func Deposit(c *gin.Context) {
+	if !requirePayments(c) {
+		return
+	}
 	var input DepositInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userID, _ := c.Get("userID")
-	
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		// Update User Balance
-		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("balance", gorm.Expr("balance + ?", input.Amount)).Error; err != nil {
-			return err
-		}
+	if input.Amount.Sign() <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	}
+	if !input.Amount.Round(ledger.Decimals).Equal(input.Amount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount has too many decimal places"})
+		return
+	}
 
-		// Create Wallet Log
-		log := models.WalletLog{
-			UserID:      userID.(uint),
-			Amount:      input.Amount,
-			Type:        "deposit",
-			Description: "User deposit",
-		}
-		if err := tx.Create(&log).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+	userID, _ := c.Get("userID")
 
+	// Nothing is credited until the payment provider confirms the payment
+	intent, err := PaymentService.CreateIntent(c.Request.Context(), userID.(uint), input.Amount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Deposit failed"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Deposit successful"})
+	c.JSON(http.StatusOK, gin.H{"message": "Deposit awaiting payment", "data": intent})
 }
 
+// GetBalance returns the balance of the user's wallet account in the ledger.
 func GetBalance(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	var user models.User
-	if err := database.DB.Select("balance").First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	var account models.LedgerAccount
+	err := database.DB.Where("user_id = ?", userID).First(&account).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"balance": user.Balance})
+	c.JSON(http.StatusOK, gin.H{"balance": ledger.FromMinor(account.Balance), "currency": rates.WalletCurrency})
+}
+
+var (
+	errQuoteUsed        = errors.New("quote already used")
+	errQuoteExpired     = errors.New("quote expired")
+	errAlreadyPurchased = errors.New("already purchased")
+)
+
+// priceCurrency returns the currency an article is priced in.
+func priceCurrency(article *models.Article) string {
+	if article.PriceCurrency == "" {
+		return rates.WalletCurrency
+	}
+	return article.PriceCurrency
+}
+
+// newPriceQuote converts the article's price into the wallet currency at the
+// current rate and locks it for the user for rates.QuoteTTL.
+func newPriceQuote(ctx context.Context, userID uint, article *models.Article) (*models.PriceQuote, error) {
+	conv, err := RateService.Convert(ctx, priceCurrency(article), rates.WalletCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if conv.Stale {
+		return nil, rates.ErrRateUnavailable
+	}
+	price := money.New(article.Price, conv.From)
+	charge := price.Convert(conv.Rate, conv.To).Round(ledger.Decimals)
+	quote := &models.PriceQuote{
+		UserID:        userID,
+		ArticleID:     article.ID,
+		Price:         price.Amount,
+		PriceCurrency: price.Currency,
+		Amount:        charge.Amount,
+		Currency:      charge.Currency,
+		Rate:          conv.Rate,
+		ExpiresAt:     time.Now().Add(rates.QuoteTTL),
+	}
+	if err := database.DB.Create(quote).Error; err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// CreatePriceQuote locks the price of a paid article in the wallet currency,
+// so the amount shown at checkout is the amount charged.
+func CreatePriceQuote(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	var article models.Article
+	if err := database.DB.First(&article, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return
+	}
+	if !article.IsPaid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Article is free"})
+		return
+	}
+
+	quote, err := newPriceQuote(c.Request.Context(), uid, &article)
+	if err != nil {
+		if errors.Is(err, rates.ErrRateUnavailable) || errors.Is(err, rates.ErrUnknownCurrency) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Exchange rate unavailable"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create quote"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": quote})
+}
+
+// checkQuote reports whether quote can still pay for a purchase at now.
+func checkQuote(quote *models.PriceQuote, now time.Time) error {
+	if quote.UsedAt != nil {
+		return errQuoteUsed
+	}
+	if now.After(quote.ExpiresAt) {
+		return errQuoteExpired
+	}
+	return nil
+}
+
+// consumeQuote marks quote used, so it pays for one purchase only. The update
+// is conditional, a concurrent purchase with the same quote gets errQuoteUsed.
+func consumeQuote(tx *gorm.DB, quote *models.PriceQuote, now time.Time) error {
+	if err := checkQuote(quote, now); err != nil {
+		return err
+	}
+	res := tx.Model(&models.PriceQuote{}).Where("id = ? AND used_at IS NULL AND expires_at >= ?", quote.ID, now).Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errQuoteUsed
+	}
+	return nil
+}
+
+func quoteErrorMessage(err error) string {
+	if errors.Is(err, errQuoteExpired) {
+		return "Quote expired"
+	}
+	return "Quote already used"
+}
+
+type PurchaseInput struct {
+	QuoteID *uint `json:"quote_id"` // Optional, a quote is made at the current rate without it
 }
 
 func PurchaseArticle(c *gin.Context) {
@@ -64,6 +180,12 @@ func PurchaseArticle(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	uid := userID.(uint)
 
+	var input PurchaseInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var article models.Article
 	if err := database.DB.First(&article, articleID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
@@ -80,31 +202,60 @@ func PurchaseArticle(c *gin.Context) {
 		return
 	}
 
-	// Check if already purchased
-	var count int64
-	database.DB.Model(&models.Purchase{}).Where("user_id = ? AND article_id = ?", uid, article.ID).Count(&count)
-	if count > 0 {
+	// Checked before pricing so a repeat purchase leaves no quote behind, and
+	// again under lock below against concurrent purchases
+	var purchased int64
+	if err := database.DB.Model(&models.Purchase{}).Where("user_id = ? AND article_id = ?", uid, article.ID).Count(&purchased).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Purchase failed"})
+		return
+	}
+	if purchased > 0 {
 		c.JSON(http.StatusOK, gin.H{"message": "Already purchased"})
 		return
 	}
 
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		var user models.User
-		if err := tx.First(&user, uid).Error; err != nil {
-			return err
+	var quote *models.PriceQuote
+	if input.QuoteID != nil {
+		quote = &models.PriceQuote{}
+		if err := database.DB.Where("id = ? AND user_id = ? AND article_id = ?", *input.QuoteID, uid, article.ID).First(quote).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quote not found"})
+			return
+		}
+		if err := checkQuote(quote, time.Now()); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": quoteErrorMessage(err)})
+			return
+		}
+	} else {
+		var err error
+		if quote, err = newPriceQuote(c.Request.Context(), uid, &article); err != nil {
+			if errors.Is(err, rates.ErrRateUnavailable) || errors.Is(err, rates.ErrUnknownCurrency) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Exchange rate unavailable"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Purchase failed"})
+			}
+			return
 		}
+	}
 
-		if user.Balance < article.Price {
-			return  gorm.ErrInvalidData // Not enough balance
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the buyer, and the author in id order like ledger.Post, so
+		// concurrent purchases by the same buyer run one at a time
+		var users []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id IN ?", []uint{uid, article.AuthorID}).Order("id").Find(&users).Error; err != nil {
+			return err
 		}
 
-		// Deduct from buyer
-		if err := tx.Model(&user).UpdateColumn("balance", gorm.Expr("balance - ?", article.Price)).Error; err != nil {
+		// Check if already purchased
+		var count int64
+		if err := tx.Model(&models.Purchase{}).Where("user_id = ? AND article_id = ?", uid, article.ID).Count(&count).Error; err != nil {
 			return err
 		}
+		if count > 0 {
+			return errAlreadyPurchased
+		}
 
-		// Add to author
-		if err := tx.Model(&models.User{}).Where("id = ?", article.AuthorID).UpdateColumn("balance", gorm.Expr("balance + ?", article.Price)).Error; err != nil {
+		if err := consumeQuote(tx, quote, time.Now()); err != nil {
 			return err
 		}
 
@@ -112,15 +263,39 @@ func PurchaseArticle(c *gin.Context) {
 		purchase := models.Purchase{
 			UserID:    uid,
 			ArticleID: article.ID,
+			QuoteID:   &quote.ID,
+			Amount:    quote.Amount,
+			Currency:  quote.Currency,
+			Rate:      quote.Rate,
 		}
 		if err := tx.Create(&purchase).Error; err != nil {
+			if database.IsDuplicateKey(err) {
+				return errAlreadyPurchased
+			}
 			return err
 		}
 
+		// Move the money from the buyer's wallet to the author's
+		if amount := ledger.ToMinor(quote.Amount); amount > 0 {
+			buyer, err := ledger.UserAccount(tx, uid)
+			if err != nil {
+				return err
+			}
+			author, err := ledger.UserAccount(tx, article.AuthorID)
+			if err != nil {
+				return err
+			}
+			reference := fmt.Sprintf("purchase:%d", purchase.ID)
+			if _, err := ledger.Transfer(tx, "purchase", reference, "Purchased article: "+article.Title, buyer.ID, author.ID, amount); err != nil {
+				return err
+			}
+		}
+
 		// Log for Buyer
 		buyerLog := models.WalletLog{
 			UserID:      uid,
-			Amount:      -article.Price,
+			Amount:      quote.Amount.Neg(),
+			Currency:    quote.Currency,
 			Type:        "purchase",
 			Description: "Purchased article: " + article.Title,
 		}
@@ -131,7 +306,8 @@ func PurchaseArticle(c *gin.Context) {
 		// Log for Seller
 		sellerLog := models.WalletLog{
 			UserID:      article.AuthorID,
-			Amount:      article.Price,
+			Amount:      quote.Amount,
+			Currency:    quote.Currency,
 			Type:        "sale",
 			Description: "Sold article: " + article.Title,
 		}
@@ -143,13 +319,17 @@ func PurchaseArticle(c *gin.Context) {
 	})
 
 	if err != nil {
-		if err == gorm.ErrInvalidData {
+		if errors.Is(err, errAlreadyPurchased) {
+			c.JSON(http.StatusOK, gin.H{"message": "Already purchased"})
+		} else if errors.Is(err, ledger.ErrInsufficientFunds) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
+		} else if errors.Is(err, errQuoteUsed) || errors.Is(err, errQuoteExpired) {
+			c.JSON(http.StatusConflict, gin.H{"error": quoteErrorMessage(err)})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Purchase failed"})
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Purchase successful"})
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase successful", "amount": quote.Amount, "currency": quote.Currency})
 }
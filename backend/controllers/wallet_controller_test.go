@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"coin-wave/database/dbtest"
+	"coin-wave/models"
+	"coin-wave/money"
+)
+
+func TestCheckQuote(t *testing.T) {
+	now := time.Now()
+	used := now.Add(-time.Second)
+	tests := []struct {
+		name  string
+		quote models.PriceQuote
+		want  error
+	}{
+		{"fresh", models.PriceQuote{ExpiresAt: now.Add(time.Minute)}, nil},
+		{"expires now", models.PriceQuote{ExpiresAt: now}, nil},
+		{"expired", models.PriceQuote{ExpiresAt: now.Add(-time.Nanosecond)}, errQuoteExpired},
+		{"used", models.PriceQuote{ExpiresAt: now.Add(time.Minute), UsedAt: &used}, errQuoteUsed},
+		{"used and expired", models.PriceQuote{ExpiresAt: now.Add(-time.Minute), UsedAt: &used}, errQuoteUsed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkQuote(&tt.quote, now); !errors.Is(err, tt.want) {
+				t.Errorf("checkQuote = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumeQuote(t *testing.T) {
+	db := dbtest.Open(t, &models.PriceQuote{})
+	now := time.Now()
+	newQuote := func(expiresAt time.Time) *models.PriceQuote {
+		t.Helper()
+		quote := &models.PriceQuote{
+			UserID:        1,
+			ArticleID:     1,
+			Price:         money.MustParse("10"),
+			PriceCurrency: "USD",
+			Amount:        money.MustParse("10"),
+			Currency:      "USD",
+			Rate:          money.MustParse("1"),
+			ExpiresAt:     expiresAt,
+		}
+		if err := db.Create(quote).Error; err != nil {
+			t.Fatal(err)
+		}
+		return quote
+	}
+
+	quote := newQuote(now.Add(time.Minute))
+	// A concurrent purchase read the quote before it was used
+	racing := *quote
+	if err := consumeQuote(db, quote, now); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := consumeQuote(db, &racing, now); !errors.Is(err, errQuoteUsed) {
+		t.Errorf("reuse = %v, want errQuoteUsed", err)
+	}
+
+	var stored models.PriceQuote
+	if err := db.First(&stored, quote.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := checkQuote(&stored, now); !errors.Is(err, errQuoteUsed) {
+		t.Errorf("stored quote check = %v, want errQuoteUsed", err)
+	}
+
+	expired := newQuote(now.Add(-time.Minute))
+	if err := consumeQuote(db, expired, now); !errors.Is(err, errQuoteExpired) {
+		t.Errorf("expired quote = %v, want errQuoteExpired", err)
+	}
+	if err := db.First(&stored, expired.ID).Error; err != nil || stored.UsedAt != nil {
+		t.Errorf("expired quote was marked used (%v)", err)
+	}
+}
@@ -3,10 +3,12 @@ package database
 import (
 	"coin-wave/models"
 	"context"
+	"errors"
 	"log"
 	"os"
 	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -42,11 +44,17 @@ func InitDB() {
 		log.Fatal("Failed to connect to database after retries:", err)
 	}
 
-	// Auto Migrate
-	err = DB.AutoMigrate(&models.User{}, &models.Article{}, &models.Bookmark{}, &models.WalletLog{}, &models.Purchase{})
+	// Data migrations that must see the old schema, then Auto Migrate
+	if err := runMigrations(DB, true); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	err = DB.AutoMigrate(&models.User{}, &models.Article{}, &models.Bookmark{}, &models.WalletLog{}, &models.Purchase{}, &models.Chunk{}, &models.ChatSession{}, &models.ChatMessage{}, &models.IngestionJob{}, &models.ArticleEmbedding{}, &models.ArticleView{}, &models.TagFollow{}, &models.RateSnapshot{}, &models.RateCandle{}, &models.PriceQuote{}, &models.AlertRule{}, &models.Notification{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.IdempotencyRecord{}, &models.DepositIntent{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if err := runMigrations(DB, false); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 	log.Println("MySQL database connected and migrated successfully.")
 
 	// Redis Connection
@@ -65,3 +73,9 @@ func InitDB() {
 		log.Println("Redis connected successfully.")
 	}
 }
+
+// IsDuplicateKey reports whether err is a MySQL unique key violation.
+func IsDuplicateKey(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -0,0 +1,65 @@
+// Package dbtest gives tests a scratch MySQL database.
+package dbtest
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DSNEnv names the variable with the DSN of the MySQL server tests run
+// against, e.g. "root:rootpassword@tcp(localhost:3306)/". The user must be
+// allowed to create databases.
+const DSNEnv = "TEST_MYSQL_DSN"
+
+// Open creates an empty database with the tables of the given models and
+// drops it when the test ends. The test is skipped if DSNEnv is not set.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", DSNEnv)
+	}
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("%s: %v", DSNEnv, err)
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.DBName = ""
+	config := &gorm.Config{Logger: logger.Discard}
+
+	server, err := gorm.Open(mysql.Open(cfg.FormatDSN()), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := fmt.Sprintf("coinwave_test_%d", time.Now().UnixNano())
+	if err := server.Exec("CREATE DATABASE " + name + " CHARACTER SET utf8mb4").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.DBName = name
+	db, err := gorm.Open(mysql.Open(cfg.FormatDSN()), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		server.Exec("DROP DATABASE " + name)
+		if sqlDB, err := server.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
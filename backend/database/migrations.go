@@ -0,0 +1,170 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"coin-wave/rates"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// schemaMigration records a data migration that has been applied. Schema
+// changes AutoMigrate can make on its own do not need one.
+type schemaMigration struct {
+	Version   string `gorm:"primaryKey;size:64"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+type migration struct {
+	version string
+	early   bool // Runs before AutoMigrate, which would otherwise convert the columns itself
+	run     func(db *gorm.DB) error
+}
+
+var migrations = []migration{
+	{"0001_decimal_money", true, migrateDecimalMoney},
+	{"0002_wallet_log_currency", false, migrateWalletLogCurrency},
+	{"0003_article_view_day", true, migrateArticleViewDay},
+}
+
+// runMigrations applies the pending migrations of one phase in order.
+func runMigrations(db *gorm.DB, early bool) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.early != early {
+			continue
+		}
+		var count int64
+		if err := db.Model(&schemaMigration{}).Where("version = ?", m.version).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		log.Printf("Applying migration %s", m.version)
+		if err := m.run(db); err != nil {
+			return fmt.Errorf("migration %s: %w", m.version, err)
+		}
+		if err := db.Create(&schemaMigration{Version: m.version, AppliedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateDecimalMoney converts the money columns from DOUBLE to DECIMAL(36,18).
+// Each value is written as the shortest decimal that reads back as the same
+// double, so 0.1 becomes exactly 0.1 instead of the binary fraction rounded
+// to 18 places.
+func migrateDecimalMoney(db *gorm.DB) error {
+	columns := []struct{ table, column string }{
+		{"users", "balance"},
+		{"articles", "price"},
+		{"wallet_logs", "amount"},
+		{"purchases", "amount"},
+		{"purchases", "rate"},
+		{"price_quotes", "price"},
+		{"price_quotes", "amount"},
+		{"price_quotes", "rate"},
+	}
+	for _, c := range columns {
+		if err := convertToDecimal(db, c.table, c.column); err != nil {
+			return fmt.Errorf("%s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// convertToDecimal copies a floating point column into a new DECIMAL column
+// and swaps them. It can be re-run after a failure part way through: a
+// crash between dropping the old column and renaming the new one is finished
+// off on the next run.
+func convertToDecimal(db *gorm.DB, table, column string) error {
+	m := db.Migrator()
+	if !m.HasTable(table) {
+		return nil
+	}
+	types, err := m.ColumnTypes(table)
+	if err != nil {
+		return err
+	}
+	tmp := column + "_decimal"
+	hasColumn, hasTmp, isFloat := false, false, false
+	for _, ct := range types {
+		switch ct.Name() {
+		case column:
+			hasColumn = true
+			t := strings.ToLower(ct.DatabaseTypeName())
+			isFloat = t == "double" || t == "float"
+		case tmp:
+			hasTmp = true
+		}
+	}
+	if !hasColumn && hasTmp {
+		return renameDecimalColumn(db, table, tmp, column)
+	}
+	if !isFloat {
+		return nil // Missing or already converted
+	}
+
+	if hasTmp {
+		if err := m.DropColumn(table, tmp); err != nil {
+			return err
+		}
+	}
+	err = db.Exec("ALTER TABLE ? ADD COLUMN ? DECIMAL(36,18) NULL", clause.Table{Name: table}, clause.Column{Name: tmp}).Error
+	if err != nil {
+		return err
+	}
+
+	// MySQL prints a double as its shortest round-trip decimal, going through
+	// CHAR keeps that instead of casting the binary fraction directly
+	err = db.Exec("UPDATE ? SET ? = CAST(CAST(? AS CHAR) AS DECIMAL(36,18))",
+		clause.Table{Name: table}, clause.Column{Name: tmp}, clause.Column{Name: column}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := m.DropColumn(table, column); err != nil {
+		return err
+	}
+	return renameDecimalColumn(db, table, tmp, column)
+}
+
+func renameDecimalColumn(db *gorm.DB, table, from, to string) error {
+	return db.Exec("ALTER TABLE ? CHANGE ? ? DECIMAL(36,18)",
+		clause.Table{Name: table}, clause.Column{Name: from}, clause.Column{Name: to}).Error
+}
+
+// migrateWalletLogCurrency marks the wallet logs written before they had a
+// currency as being in the wallet currency.
+func migrateWalletLogCurrency(db *gorm.DB) error {
+	return db.Table("wallet_logs").Where("currency = '' OR currency IS NULL").
+		Update("currency", rates.WalletCurrency).Error
+}
+
+// migrateArticleViewDay gives the article views their day and keeps the first
+// view of each user, article and day, so AutoMigrate can add the unique index.
+func migrateArticleViewDay(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable("article_views") || m.HasColumn("article_views", "day") {
+		return nil
+	}
+	if err := db.Exec("ALTER TABLE article_views ADD COLUMN day DATE NULL").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("UPDATE article_views SET day = DATE(created_at)").Error; err != nil {
+		return err
+	}
+	return db.Exec(`DELETE v FROM article_views v
+		JOIN article_views k ON k.user_id = v.user_id AND k.article_id = v.article_id
+			AND k.day = v.day AND k.id < v.id`).Error
+}
@@ -0,0 +1,103 @@
+package ledger
+
+import (
+	"time"
+
+	"coin-wave/models"
+	"coin-wave/money"
+
+	"gorm.io/gorm"
+)
+
+// Report is the outcome of Check. OK is true when every invariant holds.
+type Report struct {
+	OK                bool              `json:"ok"`
+	PostingsTotal     int64             `json:"postings_total"` // Sum of all postings, must be zero
+	Entries           int64             `json:"entries"`
+	UnbalancedEntries []uint            `json:"unbalanced_entries"`
+	AccountMismatches []AccountMismatch `json:"account_mismatches"`
+	UserMismatches    []UserMismatch    `json:"user_mismatches"`
+	CheckedAt         time.Time         `json:"checked_at"`
+}
+
+// AccountMismatch is an account whose balance snapshot differs from the sum
+// of its postings.
+type AccountMismatch struct {
+	AccountID uint   `json:"account_id"`
+	Code      string `json:"code"`
+	Balance   int64  `json:"balance"`
+	Postings  int64  `json:"postings"`
+}
+
+// UserMismatch is a user whose User.Balance differs from their wallet account,
+// or who has no wallet account.
+type UserMismatch struct {
+	UserID         uint          `json:"user_id"`
+	Balance        money.Decimal `json:"balance"`
+	AccountBalance *int64        `json:"account_balance"` // Minor units, nil without an account
+}
+
+// Check verifies the ledger invariants: all postings sum to zero, each entry
+// is balanced, every account snapshot matches its postings and every user's
+// balance matches their wallet account.
+func Check(db *gorm.DB) (*Report, error) {
+	report := &Report{
+		UnbalancedEntries: []uint{},
+		AccountMismatches: []AccountMismatch{},
+		UserMismatches:    []UserMismatch{},
+		CheckedAt:         time.Now(),
+	}
+
+	err := db.Model(&models.Posting{}).Select("COALESCE(SUM(amount), 0)").Scan(&report.PostingsTotal).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.JournalEntry{}).Count(&report.Entries).Error; err != nil {
+		return nil, err
+	}
+
+	err = db.Model(&models.Posting{}).Select("entry_id").Group("entry_id").
+		Having("SUM(amount) <> 0").Order("entry_id").Scan(&report.UnbalancedEntries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Table("ledger_accounts AS a").
+		Select("a.id AS account_id, a.code, a.balance, COALESCE(SUM(p.amount), 0) AS postings").
+		Joins("LEFT JOIN postings p ON p.account_id = a.id").
+		Where("a.deleted_at IS NULL").
+		Group("a.id, a.code, a.balance").
+		Having("a.balance <> COALESCE(SUM(p.amount), 0)").
+		Scan(&report.AccountMismatches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type userRow struct {
+		UserID         uint
+		Balance        money.Decimal
+		AccountBalance *int64
+	}
+	var rows []userRow
+	err = db.Table("users AS u").
+		Select("u.id AS user_id, u.balance, a.balance AS account_balance").
+		Joins("LEFT JOIN ledger_accounts a ON a.user_id = u.id AND a.deleted_at IS NULL").
+		Where("u.deleted_at IS NULL").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if r.AccountBalance == nil && r.Balance.IsZero() {
+			continue // Never had money, the account is opened on first use
+		}
+		// The snapshot must be exact, not merely round to the account balance
+		if r.AccountBalance == nil || !r.Balance.Equal(FromMinor(*r.AccountBalance)) {
+			report.UserMismatches = append(report.UserMismatches, UserMismatch(r))
+		}
+	}
+
+	report.OK = report.PostingsTotal == 0 && len(report.UnbalancedEntries) == 0 &&
+		len(report.AccountMismatches) == 0 && len(report.UserMismatches) == 0
+	return report, nil
+}
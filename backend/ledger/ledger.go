@@ -0,0 +1,187 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"coin-wave/models"
+	"coin-wave/money"
+	"coin-wave/rates"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Decimals is the number of decimal places of the wallet currency; postings
+// and account balances are stored in units of 10^-Decimals. It is fixed,
+// changing it would rescale every stored balance.
+const Decimals = 2
+
+// System accounts, the other side of money entering or leaving user wallets.
+const (
+	AccountDeposits = "system:deposits"
+	AccountOpening  = "system:opening"
+)
+
+var (
+	ErrUnbalanced        = errors.New("postings do not sum to zero")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// ToMinor converts an amount in the wallet currency to minor units, rounding
+// half away from zero.
+func ToMinor(amount money.Decimal) int64 {
+	return amount.Minor(Decimals)
+}
+
+// FromMinor converts minor units to an amount in the wallet currency.
+func FromMinor(minor int64) money.Decimal {
+	return money.FromMinor(minor, Decimals)
+}
+
+// UserAccount returns the wallet account of a user, creating it if needed.
+func UserAccount(tx *gorm.DB, userID uint) (*models.LedgerAccount, error) {
+	return account(tx, fmt.Sprintf("user:%d", userID), &userID)
+}
+
+// SystemAccount returns a system account by code, creating it if needed.
+func SystemAccount(tx *gorm.DB, code string) (*models.LedgerAccount, error) {
+	return account(tx, code, nil)
+}
+
+func account(tx *gorm.DB, code string, userID *uint) (*models.LedgerAccount, error) {
+	var acc models.LedgerAccount
+	err := tx.Where("code = ?", code).First(&acc).Error
+	if err == nil {
+		return &acc, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Another transaction may create it first, so read it back either way
+	acc = models.LedgerAccount{Code: code, UserID: userID, Currency: rates.WalletCurrency}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&acc).Error; err != nil {
+		return nil, err
+	}
+	acc = models.LedgerAccount{}
+	if err := tx.Where("code = ?", code).First(&acc).Error; err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// Post records a journal entry with its postings and updates the balance
+// snapshots of the accounts involved, including User.Balance for wallets.
+// Postings must sum to zero, and user accounts cannot go below zero. Call it
+// inside a transaction; the accounts stay locked until it ends.
+func Post(tx *gorm.DB, entry *models.JournalEntry) error {
+	return post(tx, entry, false)
+}
+
+func post(tx *gorm.DB, entry *models.JournalEntry, allowOverdraft bool) error {
+	deltas, err := entryDeltas(entry.Postings)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(deltas))
+	for id := range deltas {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// Lock the users, then the accounts, each in id order, so concurrent
+	// transfers cannot deadlock. Users come first because their balance
+	// snapshots are updated below; callers may lock them earlier too.
+	var userIDs []uint
+	if err := tx.Model(&models.LedgerAccount{}).Where("id IN ? AND user_id IS NOT NULL", ids).Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id IN ?", userIDs).Order("id").Find(&users).Error; err != nil {
+			return err
+		}
+	}
+	var accounts []models.LedgerAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&accounts).Error; err != nil {
+		return err
+	}
+	if len(accounts) != len(ids) {
+		return fmt.Errorf("unknown ledger account")
+	}
+	if err := checkFunds(accounts, deltas, allowOverdraft); err != nil {
+		return err
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	for _, acc := range accounts {
+		balance := acc.Balance + deltas[acc.ID]
+		if err := tx.Model(&acc).UpdateColumn("balance", balance).Error; err != nil {
+			return err
+		}
+		if acc.UserID != nil {
+			if err := tx.Model(&models.User{}).Where("id = ?", *acc.UserID).UpdateColumn("balance", FromMinor(balance)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entryDeltas validates the postings of an entry and returns the balance
+// change of each account.
+func entryDeltas(postings []models.Posting) (map[uint]int64, error) {
+	if len(postings) < 2 {
+		return nil, fmt.Errorf("journal entry needs at least two postings")
+	}
+	deltas := make(map[uint]int64)
+	var sum int64
+	for _, p := range postings {
+		if p.Amount == 0 {
+			return nil, fmt.Errorf("posting to account %d has no amount", p.AccountID)
+		}
+		deltas[p.AccountID] += p.Amount
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return nil, ErrUnbalanced
+	}
+	return deltas, nil
+}
+
+// checkFunds refuses deltas that would take money from a user account
+// below zero. Accounts that only receive money are never refused.
+func checkFunds(accounts []models.LedgerAccount, deltas map[uint]int64, allowOverdraft bool) error {
+	if allowOverdraft {
+		return nil
+	}
+	for _, acc := range accounts {
+		if acc.UserID != nil && deltas[acc.ID] < 0 && acc.Balance+deltas[acc.ID] < 0 {
+			return ErrInsufficientFunds
+		}
+	}
+	return nil
+}
+
+// Transfer posts a two-legged entry moving amount minor units from one
+// account to another.
+func Transfer(tx *gorm.DB, entryType, reference, description string, from, to uint, amount int64) (*models.JournalEntry, error) {
+	entry := &models.JournalEntry{
+		Type:        entryType,
+		Reference:   reference,
+		Description: description,
+		Postings: []models.Posting{
+			{AccountID: from, Amount: -amount},
+			{AccountID: to, Amount: amount},
+		},
+	}
+	if err := Post(tx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,138 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"coin-wave/models"
+	"coin-wave/money"
+)
+
+func TestEntryDeltas(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []models.Posting
+		want     map[uint]int64
+		wantErr  error // nil with wantFail means any error
+		wantFail bool
+	}{
+		{
+			name:     "transfer",
+			postings: []models.Posting{{AccountID: 1, Amount: -500}, {AccountID: 2, Amount: 500}},
+			want:     map[uint]int64{1: -500, 2: 500},
+		},
+		{
+			name:     "split",
+			postings: []models.Posting{{AccountID: 1, Amount: -1000}, {AccountID: 2, Amount: 900}, {AccountID: 3, Amount: 100}},
+			want:     map[uint]int64{1: -1000, 2: 900, 3: 100},
+		},
+		{
+			name:     "same account twice",
+			postings: []models.Posting{{AccountID: 1, Amount: -300}, {AccountID: 1, Amount: -200}, {AccountID: 2, Amount: 500}},
+			want:     map[uint]int64{1: -500, 2: 500},
+		},
+		{
+			name:     "unbalanced",
+			postings: []models.Posting{{AccountID: 1, Amount: -500}, {AccountID: 2, Amount: 499}},
+			wantErr:  ErrUnbalanced,
+			wantFail: true,
+		},
+		{
+			name:     "single posting",
+			postings: []models.Posting{{AccountID: 1, Amount: 0}},
+			wantFail: true,
+		},
+		{
+			name:     "zero amount",
+			postings: []models.Posting{{AccountID: 1, Amount: 0}, {AccountID: 2, Amount: 0}},
+			wantFail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := entryDeltas(tt.postings)
+			if tt.wantFail {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("entryDeltas() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("entryDeltas() = %v, want %v", got, tt.want)
+			}
+			for id, d := range tt.want {
+				if got[id] != d {
+					t.Errorf("entryDeltas()[%d] = %d, want %d", id, got[id], d)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckFunds(t *testing.T) {
+	newAccount := func(id uint, userID *uint, balance int64) models.LedgerAccount {
+		acc := models.LedgerAccount{UserID: userID, Balance: balance}
+		acc.ID = id
+		return acc
+	}
+	alice, bob := uint(7), uint(8)
+	accounts := []models.LedgerAccount{
+		newAccount(1, &alice, 1000),
+		newAccount(2, nil, -5000), // System account
+		newAccount(3, &bob, -200), // Overdrawn by an opening entry
+	}
+
+	tests := []struct {
+		name           string
+		deltas         map[uint]int64
+		allowOverdraft bool
+		wantErr        error
+	}{
+		{"spend part", map[uint]int64{1: -400, 2: 400}, false, nil},
+		{"spend all", map[uint]int64{1: -1000, 2: 1000}, false, nil},
+		{"overdraft", map[uint]int64{1: -1001, 2: 1001}, false, ErrInsufficientFunds},
+		{"overdraft allowed", map[uint]int64{1: -1001, 2: 1001}, true, nil},
+		{"system accounts go negative", map[uint]int64{2: -1000, 1: 1000}, false, nil},
+		{"overdrawn account receives", map[uint]int64{2: -100, 3: 100}, false, nil},
+		{"overdrawn account spends", map[uint]int64{3: -1, 2: 1}, false, ErrInsufficientFunds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkFunds(accounts, tt.deltas, tt.allowOverdraft); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkFunds() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount string
+		minor  int64
+		back   string
+	}{
+		{"12.34", 1234, "12.34"},
+		{"0.005", 1, "0.01"},
+		{"-0.005", -1, "-0.01"},
+		{"0.004", 0, "0"},
+		{"-12.345", -1235, "-12.35"},
+		{"92233720368547758.07", 9223372036854775807, "92233720368547758.07"},
+	}
+	for _, tt := range tests {
+		amount, err := money.Parse(tt.amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		minor := ToMinor(amount)
+		if minor != tt.minor {
+			t.Errorf("ToMinor(%s) = %d, want %d", tt.amount, minor, tt.minor)
+		}
+		back, _ := money.Parse(tt.back)
+		if got := FromMinor(minor); !got.Equal(back) {
+			t.Errorf("FromMinor(%d) = %s, want %s", minor, got, tt.back)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ledger
+
+import (
+	"fmt"
+	"log"
+
+	"coin-wave/models"
+
+	"gorm.io/gorm"
+)
+
+// MigrateOpeningBalances gives every user without a wallet account one, with
+// an opening entry for the balance they had before the ledger. Users who
+// already have an account are skipped, so it is safe to run on every start.
+// A balance that does not fit in minor units is an error, to be corrected by
+// hand before the migration can finish.
+func MigrateOpeningBalances(db *gorm.DB) error {
+	var users []models.User
+	migrated := 0
+	err := db.Select("id, balance").
+		Where("NOT EXISTS (SELECT 1 FROM ledger_accounts WHERE ledger_accounts.user_id = users.id)").
+		FindInBatches(&users, 200, func(_ *gorm.DB, _ int) error {
+			for _, user := range users {
+				err := db.Transaction(func(tx *gorm.DB) error {
+					acc, err := UserAccount(tx, user.ID)
+					if err != nil {
+						return err
+					}
+					// Rounding would silently change the balance
+					if !user.Balance.Round(Decimals).Equal(user.Balance) {
+						return fmt.Errorf("user %d has balance %s with more than %d decimal places", user.ID, user.Balance, Decimals)
+					}
+					amount := ToMinor(user.Balance)
+					if amount == 0 || acc.Balance != 0 {
+						return nil
+					}
+					opening, err := SystemAccount(tx, AccountOpening)
+					if err != nil {
+						return err
+					}
+					return post(tx, &models.JournalEntry{
+						Type:        "opening",
+						Description: "Opening balance",
+						Postings: []models.Posting{
+							{AccountID: opening.ID, Amount: -amount},
+							{AccountID: acc.ID, Amount: amount},
+						},
+					}, true)
+				})
+				if err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		}).Error
+	if migrated > 0 {
+		log.Printf("[Ledger] Opened wallet accounts for %d users", migrated)
+	}
+	return err
+}
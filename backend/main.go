@@ -3,11 +3,17 @@ package main
 import (
 	"context"
 	"log"
+	"strings"
 	"time"
 
+	"coin-wave/alerts"
 	"coin-wave/controllers"
 	"coin-wave/database"
+	"coin-wave/ledger"
+	"coin-wave/middleware"
+	"coin-wave/payments"
 	"coin-wave/rag"
+	"coin-wave/rates"
 	"coin-wave/routes"
 
 	"github.com/gin-contrib/cors"
@@ -16,43 +22,88 @@ import (
 
 func main() {
 	database.InitDB()
+	if err := ledger.MigrateOpeningBalances(database.DB); err != nil {
+		log.Fatalf("Failed to migrate wallet balances to the ledger: %v", err)
+	}
+	if err := controllers.InitSearch(); err != nil {
+		log.Printf("Warning: Failed to build article search index: %v", err)
+	}
 
 	// Initialize RAG Components
 	ctx := context.Background()
-	milvusStore, err := rag.NewMilvusStore(ctx)
+	embedder, chatModel, err := rag.NewProviders()
 	if err != nil {
-		log.Printf("Warning: Failed to connect to Milvus: %v", err)
-	} else {
-		// Initialize Collection
-		if err := milvusStore.InitCollection(ctx); err != nil {
-			log.Printf("Warning: Failed to init Milvus collection: %v", err)
-		}
+		log.Fatalf("Failed to init RAG provider: %v", err)
 	}
 
-	volcClient := rag.NewVolcClient()
-
+	// Without the vector store RAG is turned off and the rest of the app keeps
+	// running. There is no fallback to the local store, vectors written there
+	// would diverge from Milvus; it is only used with VECTOR_STORE=local.
 	var ragService *rag.RagService
-	var ingestionWorker *rag.IngestionWorker
+	var jobQueue *rag.JobQueue
+	vectorStore, err := rag.NewVectorStore(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to vector store %q, RAG is disabled: %v", rag.VectorStoreType, err)
+	} else {
+		// Initialize Collection
+		if err := vectorStore.InitCollection(ctx); err != nil {
+			log.Printf("Warning: Failed to init vector store: %v", err)
+		}
 
-	if milvusStore != nil {
-		ragService = rag.NewRagService(database.DB, volcClient, milvusStore)
-		ingestionWorker, err = rag.NewIngestionWorker(ragService)
+		ragService = rag.NewRagService(database.DB, embedder, chatModel, vectorStore)
+		if err := ragService.LoadKeywordIndex(); err != nil {
+			log.Printf("Warning: Failed to build keyword index: %v", err)
+		}
+		ingestionWorker, err := rag.NewIngestionWorker(ragService)
 		if err != nil {
 			log.Printf("Warning: Failed to create ingestion worker: %v", err)
+		} else {
+			jobQueue = rag.NewJobQueue(database.DB, ingestionWorker)
+			jobQueue.Start(ctx)
 		}
 
-		controllers.InitRag(ingestionWorker, ragService)
+		controllers.InitRag(ctx, jobQueue, ragService)
 		log.Println("RAG System Initialized Successfully")
 	}
 
+	// A bad provider config falls back to the live providers, never to the
+	// fixture, which would price checkouts at its made-up rates
+	rateProvider, err := rates.NewProviderChain()
+	if err != nil {
+		log.Printf("Warning: Failed to init rate providers, using %s: %v", strings.Join(rates.DefaultProviders, ","), err)
+		rateProvider = rates.DefaultProviderChain()
+	}
+	rateService := rates.NewService(database.DB, rateProvider, database.RDB)
+	rateService.Start(ctx)
+	controllers.InitRates(rateService)
+
+	alertEvaluator := alerts.NewEvaluator(database.DB, rateService)
+	alertEvaluator.Start(ctx)
+	controllers.InitAlerts(alertEvaluator)
+
+	paymentProvider, err := payments.NewProvider()
+	if err != nil {
+		log.Fatalf("Failed to init payment provider: %v", err)
+	}
+	if paymentProvider == nil {
+		log.Println("Warning: PAYMENT_PROVIDER is not set, deposits are disabled")
+	} else {
+		paymentService := payments.NewService(database.DB, paymentProvider)
+		paymentService.Start(ctx)
+		controllers.InitPayments(paymentService)
+	}
+
+	go middleware.PurgeIdempotencyRecords(ctx)
+	go controllers.PurgeArticleViews(ctx)
+
 	r := gin.Default()
 
 	// CORS Setup
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000", "http://localhost"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
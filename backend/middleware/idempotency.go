@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"coin-wave/database"
+	"coin-wave/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLen = 128
+	// A request still running after this long is presumed lost with its
+	// server, and its key may be claimed again.
+	idempotencyLockTimeout = time.Minute
+)
+
+// IdempotencyTTL is how long a key and its stored response are kept.
+var IdempotencyTTL = 24 * time.Hour
+
+var (
+	errKeyMismatch   = errors.New("idempotency key reused with a different request")
+	errKeyInProgress = errors.New("idempotency key in use")
+)
+
+func init() {
+	if v := os.Getenv("IDEMPOTENCY_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			IdempotencyTTL = d
+		}
+	}
+}
+
+// IdempotencyMiddleware makes a request with an Idempotency-Key header run at
+// most once per user and key: the first response is stored and replayed for
+// retries. Responses with 5xx status are not stored, so those can be retried.
+// Requests without the header pass through. It must run after AuthMiddleware.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+
+		userID, _ := c.Get("userID")
+		record := &models.IdempotencyRecord{
+			UserID:      userID.(uint),
+			Key:         key,
+			Route:       c.Request.Method + " " + c.Request.URL.Path,
+			RequestHash: hex.EncodeToString(sum[:]),
+		}
+		stored, err := claimIdempotencyKey(record)
+		switch {
+		case errors.Is(err, errKeyMismatch):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			c.Abort()
+			return
+		case errors.Is(err, errKeyInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is in progress"})
+			c.Abort()
+			return
+		case err != nil:
+			log.Printf("Idempotency check failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Idempotency check failed"})
+			c.Abort()
+			return
+		case stored != nil:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.Status, "application/json; charset=utf-8", stored.Body)
+			c.Abort()
+			return
+		}
+
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		finishIdempotentRequest(record, w.Status(), w.body.Bytes())
+	}
+}
+
+// claimIdempotencyKey reserves the key for record. It returns the stored
+// record instead if the key already has a response for the same request.
+func claimIdempotencyKey(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	if cached := cachedIdempotencyRecord(record.UserID, record.Key); cached != nil {
+		if !sameIdempotentRequest(cached, record) {
+			return nil, errKeyMismatch
+		}
+		return cached, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		now := time.Now()
+		record.ID = 0
+		record.ExpiresAt = now.Add(IdempotencyTTL)
+		err := database.DB.Create(record).Error
+		if err == nil {
+			return nil, nil
+		}
+		if !database.IsDuplicateKey(err) {
+			return nil, err
+		}
+
+		var existing models.IdempotencyRecord
+		err = database.DB.Where("user_id = ? AND `key` = ?", record.UserID, record.Key).First(&existing).Error
+		if err != nil {
+			return nil, errKeyInProgress // Released while we looked, the client may retry
+		}
+		reclaim, err := checkIdempotencyRecord(&existing, record, now)
+		if reclaim && attempt == 0 {
+			database.DB.Delete(&existing)
+			continue
+		}
+		if reclaim {
+			// Someone else reclaimed it first
+			return nil, errKeyInProgress
+		}
+		if err != nil {
+			return nil, err
+		}
+		cacheIdempotencyRecord(&existing)
+		return &existing, nil
+	}
+}
+
+// checkIdempotencyRecord decides what a request does with the record its key
+// already has. The key is reclaimed if the record expired or its request was
+// abandoned. Otherwise the stored response is replayed, unless the key was
+// used for a different request or its first request is still running.
+func checkIdempotencyRecord(existing, record *models.IdempotencyRecord, now time.Time) (reclaim bool, err error) {
+	expired := existing.ExpiresAt.Before(now)
+	abandoned := !existing.Completed && existing.CreatedAt.Before(now.Add(-idempotencyLockTimeout))
+	if expired || abandoned {
+		return true, nil
+	}
+	if !sameIdempotentRequest(existing, record) {
+		return false, errKeyMismatch
+	}
+	if !existing.Completed {
+		return false, errKeyInProgress
+	}
+	return false, nil
+}
+
+func sameIdempotentRequest(a, b *models.IdempotencyRecord) bool {
+	return a.Route == b.Route && a.RequestHash == b.RequestHash
+}
+
+func finishIdempotentRequest(record *models.IdempotencyRecord, status int, body []byte) {
+	if status >= http.StatusInternalServerError {
+		database.DB.Delete(record)
+		return
+	}
+	record.Completed = true
+	record.Status = status
+	record.Body = body
+	err := database.DB.Model(record).Updates(map[string]interface{}{
+		"completed": true,
+		"status":    status,
+		"body":      body,
+	}).Error
+	if err != nil {
+		log.Printf("Failed to store idempotent response: %v", err)
+		return
+	}
+	cacheIdempotencyRecord(record)
+}
+
+func idempotencyCacheKey(userID uint, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userID, key)
+}
+
+// idempotencyCacheEntry is the Redis copy of a completed record.
+type idempotencyCacheEntry struct {
+	Route       string    `json:"route"`
+	RequestHash string    `json:"request_hash"`
+	Status      int       `json:"status"`
+	Body        []byte    `json:"body"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func cachedIdempotencyRecord(userID uint, key string) *models.IdempotencyRecord {
+	if database.RDB == nil {
+		return nil
+	}
+	data, err := database.RDB.Get(database.Ctx, idempotencyCacheKey(userID, key)).Bytes()
+	if err != nil {
+		return nil
+	}
+	var entry idempotencyCacheEntry
+	if json.Unmarshal(data, &entry) != nil {
+		return nil
+	}
+	return &models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		Route:       entry.Route,
+		RequestHash: entry.RequestHash,
+		Completed:   true,
+		Status:      entry.Status,
+		Body:        entry.Body,
+		ExpiresAt:   entry.ExpiresAt,
+	}
+}
+
+func cacheIdempotencyRecord(record *models.IdempotencyRecord) {
+	if database.RDB == nil {
+		return
+	}
+	ttl := time.Until(record.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+	data, _ := json.Marshal(idempotencyCacheEntry{
+		Route:       record.Route,
+		RequestHash: record.RequestHash,
+		Status:      record.Status,
+		Body:        record.Body,
+		ExpiresAt:   record.ExpiresAt,
+	})
+	database.RDB.Set(database.Ctx, idempotencyCacheKey(record.UserID, record.Key), data, ttl)
+}
+
+// PurgeIdempotencyRecords deletes expired records every hour until ctx is
+// cancelled.
+func PurgeIdempotencyRecords(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := database.DB.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyRecord{}).Error; err != nil {
+				log.Printf("Failed to purge idempotency records: %v", err)
+			}
+		}
+	}
+}
+
+// capturingWriter keeps a copy of the response body.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
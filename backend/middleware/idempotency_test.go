@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"coin-wave/database"
+	"coin-wave/database/dbtest"
+	"coin-wave/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestCheckIdempotencyRecord(t *testing.T) {
+	now := time.Now()
+	request := &models.IdempotencyRecord{Route: "POST /api/v1/wallet/deposit", RequestHash: "abc"}
+	record := func(edit func(r *models.IdempotencyRecord)) *models.IdempotencyRecord {
+		r := &models.IdempotencyRecord{
+			Route:       request.Route,
+			RequestHash: request.RequestHash,
+			Completed:   true,
+			Status:      http.StatusOK,
+			ExpiresAt:   now.Add(time.Hour),
+			CreatedAt:   now.Add(-time.Second),
+		}
+		if edit != nil {
+			edit(r)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name        string
+		existing    *models.IdempotencyRecord
+		wantReclaim bool
+		wantErr     error
+	}{
+		{"replay", record(nil), false, nil},
+		{"different body", record(func(r *models.IdempotencyRecord) { r.RequestHash = "def" }), false, errKeyMismatch},
+		{"different route", record(func(r *models.IdempotencyRecord) { r.Route = "POST /api/v1/articles/1/purchase" }), false, errKeyMismatch},
+		{"running", record(func(r *models.IdempotencyRecord) { r.Completed = false }), false, errKeyInProgress},
+		{"expired", record(func(r *models.IdempotencyRecord) { r.ExpiresAt = now.Add(-time.Second) }), true, nil},
+		{"expired, other request", record(func(r *models.IdempotencyRecord) {
+			r.ExpiresAt = now.Add(-time.Second)
+			r.RequestHash = "def"
+		}), true, nil},
+		{"abandoned", record(func(r *models.IdempotencyRecord) {
+			r.Completed = false
+			r.CreatedAt = now.Add(-2 * idempotencyLockTimeout)
+		}), true, nil},
+		{"old but completed", record(func(r *models.IdempotencyRecord) { r.CreatedAt = now.Add(-2 * idempotencyLockTimeout) }), false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reclaim, err := checkIdempotencyRecord(tt.existing, request, now)
+			if reclaim != tt.wantReclaim || !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkIdempotencyRecord = %v, %v, want %v, %v", reclaim, err, tt.wantReclaim, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	db := dbtest.Open(t, &models.IdempotencyRecord{})
+	defer func(db *gorm.DB) { database.DB = db }(database.DB)
+	database.DB = db
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	failNext := false
+	r := gin.New()
+	r.POST("/deposit", func(c *gin.Context) {
+		c.Set("userID", uint(7))
+	}, IdempotencyMiddleware(), func(c *gin.Context) {
+		calls++
+		if failNext {
+			failNext = false
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Deposit failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"call": calls}})
+	})
+	post := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/deposit", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyHeader, key)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("k1", `{"amount":"10"}`)
+	if first.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("first request: %d after %d calls", first.Code, calls)
+	}
+
+	replay := post("k1", `{"amount":"10"}`)
+	if replay.Code != http.StatusOK || replay.Body.String() != first.Body.String() || calls != 1 {
+		t.Errorf("retry = %d %s after %d calls, want the first response replayed", replay.Code, replay.Body, calls)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response is not marked")
+	}
+
+	if w := post("k1", `{"amount":"20"}`); w.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("key reused for another body = %d after %d calls, want 422 without running", w.Code, calls)
+	}
+
+	// Server errors are not stored, the retry runs again
+	failNext = true
+	if w := post("k2", `{"amount":"10"}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("failing request = %d", w.Code)
+	}
+	if w := post("k2", `{"amount":"10"}`); w.Code != http.StatusOK || calls != 3 {
+		t.Errorf("retry after a 5xx = %d after %d calls, want a fresh run", w.Code, calls)
+	}
+
+	// Without a key every request runs
+	post("", `{"amount":"10"}`)
+	post("", `{"amount":"10"}`)
+	if calls != 5 {
+		t.Errorf("%d calls, want requests without a key to always run", calls)
+	}
+}
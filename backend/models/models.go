@@ -1,38 +1,47 @@
 package models
 
 import (
+	"coin-wave/money"
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Username string `gorm:"uniqueIndex;not null;size:191" json:"username"`
-	Password string `json:"-"` // Don't return password in JSON
-	Balance  float64 `gorm:"default:0" json:"balance"`
-	IsAdmin  bool    `gorm:"default:false" json:"is_admin"`
+	Username string        `gorm:"uniqueIndex;not null;size:191" json:"username"`
+	Password string        `json:"-"`                                            // Don't return password in JSON
+	Balance  money.Decimal `gorm:"type:decimal(36,18);default:0" json:"balance"` // Snapshot of the ledger wallet account
+	IsAdmin  bool          `gorm:"default:false" json:"is_admin"`
 }
 
 type Article struct {
 	gorm.Model
-	Title          string  `json:"title"`
-	Content        string  `gorm:"type:text" json:"content"`
-	AuthorID       uint    `json:"author_id"`
-	Author         User    `json:"author"`
-	Tags           string  `json:"tags"` // Comma separated tags for simplicity
-	IsPaid         bool    `gorm:"default:false" json:"is_paid"`
-	Price          float64 `gorm:"default:0" json:"price"`
-	ViewCount      int     `gorm:"default:0" json:"view_count"`
-	BookmarkCount  int     `gorm:"default:0" json:"bookmark_count"`
-	VectorStatus   string  `gorm:"default:'pending'" json:"vector_status"` // pending, processing, completed, failed
-	VectorProgress int     `gorm:"default:0" json:"vector_progress"`       // 0-100
+	Title          string        `json:"title"`
+	Content        string        `gorm:"type:text" json:"content"`
+	AuthorID       uint          `json:"author_id"`
+	Author         User          `json:"author"`
+	Tags           string        `json:"tags"` // Comma separated tags for simplicity
+	IsPaid         bool          `gorm:"default:false" json:"is_paid"`
+	Price          money.Decimal `gorm:"type:decimal(36,18);default:0" json:"price"`
+	PriceCurrency  string        `gorm:"size:8" json:"price_currency"` // Empty means the wallet currency
+	ViewCount      int           `gorm:"default:0" json:"view_count"`
+	BookmarkCount  int           `gorm:"default:0" json:"bookmark_count"`
+	VectorStatus   string        `gorm:"default:'pending'" json:"vector_status"` // pending, processing, completed, failed
+	VectorProgress int           `gorm:"default:0" json:"vector_progress"`       // 0-100
+	VectorStage    string        `gorm:"size:16" json:"vector_stage"`            // queued, fetch, chunk, embed, store, done
+	VectorError    string        `gorm:"type:text" json:"vector_error"`          // Last ingestion failure reason
 }
 
 type Chunk struct {
 	gorm.Model
-	ArticleID uint   `gorm:"index" json:"article_id"`
-	Content   string `gorm:"type:text" json:"content"`
-	ChunkIndex int    `json:"chunk_index"`
-	VectorID   int64  `json:"vector_id"` // Milvus ID
+	ArticleID   uint   `gorm:"index" json:"article_id"`
+	Content     string `gorm:"type:text" json:"content"`
+	ChunkIndex  int    `json:"chunk_index"`
+	VectorID    int64  `json:"vector_id"`    // Milvus ID
+	StartOffset int    `json:"start_offset"` // Rune offsets into Article.Content
+	EndOffset   int    `json:"end_offset"`
+	Breadcrumb  string `gorm:"size:512" json:"breadcrumb"` // Heading path, e.g. "Setup > Docker"
 }
 
 type Bookmark struct {
@@ -44,15 +53,196 @@ type Bookmark struct {
 
 type WalletLog struct {
 	gorm.Model
-	UserID      uint    `json:"user_id"`
-	Amount      float64 `json:"amount"` // Positive for deposit, negative for spend
-	Type        string  `json:"type"`   // "deposit", "purchase"
-	Description string  `json:"description"`
+	UserID      uint          `json:"user_id"`
+	Amount      money.Decimal `gorm:"type:decimal(36,18)" json:"amount"` // Positive for deposit, negative for spend
+	Currency    string        `gorm:"size:8" json:"currency"`
+	Type        string        `json:"type"` // "deposit", "purchase"
+	Description string        `json:"description"`
 }
 
 // Purchase record to track if a user bought an article
 type Purchase struct {
 	gorm.Model
-	UserID    uint `gorm:"uniqueIndex:idx_user_purchase" json:"user_id"`
-	ArticleID uint `gorm:"uniqueIndex:idx_user_purchase" json:"article_id"`
+	UserID    uint          `gorm:"uniqueIndex:idx_user_purchase" json:"user_id"`
+	ArticleID uint          `gorm:"uniqueIndex:idx_user_purchase" json:"article_id"`
+	QuoteID   *uint         `json:"quote_id"`
+	Amount    money.Decimal `gorm:"type:decimal(36,18)" json:"amount"` // Charged, in Currency
+	Currency  string        `gorm:"size:8" json:"currency"`            // Wallet currency at checkout
+	Rate      money.Decimal `gorm:"type:decimal(36,18)" json:"rate"`   // Wallet currency per unit of the article's price currency
+}
+
+// PriceQuote locks the wallet-currency price of an article for a user until
+// ExpiresAt
+type PriceQuote struct {
+	gorm.Model
+	UserID        uint          `gorm:"index" json:"user_id"`
+	ArticleID     uint          `json:"article_id"`
+	Price         money.Decimal `gorm:"type:decimal(36,18)" json:"price"` // Article price in PriceCurrency
+	PriceCurrency string        `gorm:"size:8" json:"price_currency"`
+	Amount        money.Decimal `gorm:"type:decimal(36,18)" json:"amount"` // Price converted to Currency
+	Currency      string        `gorm:"size:8" json:"currency"`
+	Rate          money.Decimal `gorm:"type:decimal(36,18)" json:"rate"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	UsedAt        *time.Time    `json:"used_at"`
+}
+
+// ChatSession is a multi-turn RAG conversation
+type ChatSession struct {
+	gorm.Model
+	UserID   uint          `gorm:"index" json:"user_id"`
+	Title    string        `json:"title"`
+	Messages []ChatMessage `gorm:"foreignKey:SessionID" json:"messages,omitempty"`
+}
+
+type ChatMessage struct {
+	gorm.Model
+	SessionID uint   `gorm:"index" json:"session_id"`
+	Role      string `gorm:"size:16" json:"role"` // "user", "assistant"
+	Content   string `gorm:"type:text" json:"content"`
+}
+
+// IngestionJob is a durable vectorization task processed by the RAG job queue
+type IngestionJob struct {
+	gorm.Model
+	ArticleID   uint       `gorm:"index" json:"article_id"`
+	Status      string     `gorm:"size:16;index;default:'queued'" json:"status"` // queued, running, succeeded, dead
+	Attempts    int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"default:5" json:"max_attempts"`
+	NextRunAt   time.Time  `gorm:"index" json:"next_run_at"`
+	LockedAt    *time.Time `json:"locked_at"`
+	LastError   string     `gorm:"type:text" json:"last_error"`
+}
+
+// ArticleEmbedding is the mean of an article's chunk vectors, used for related articles
+type ArticleEmbedding struct {
+	gorm.Model
+	ArticleID uint   `gorm:"uniqueIndex" json:"article_id"`
+	AuthorID  uint   `gorm:"index" json:"author_id"`
+	Dim       int    `json:"dim"`
+	Vector    []byte `gorm:"type:mediumblob" json:"-"` // Little-endian float32, unit length
+}
+
+// ArticleView records a signed-in user opening an article, for the personalized feed.
+// It is kept once per user, article and day, and purged after the feed's history window.
+type ArticleView struct {
+	gorm.Model
+	UserID    uint      `gorm:"uniqueIndex:idx_article_view_day" json:"user_id"`
+	ArticleID uint      `gorm:"uniqueIndex:idx_article_view_day;index" json:"article_id"`
+	Day       time.Time `gorm:"type:date;uniqueIndex:idx_article_view_day;index" json:"day"`
+}
+
+// TagFollow is a tag a user follows in the personalized feed
+type TagFollow struct {
+	gorm.Model
+	UserID uint   `gorm:"uniqueIndex:idx_user_tag" json:"user_id"`
+	Tag    string `gorm:"size:64;uniqueIndex:idx_user_tag" json:"tag"`
+}
+
+// RateSnapshot is one quote stored by the rate refresher
+type RateSnapshot struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Symbol    string    `gorm:"size:16;index:idx_symbol_time" json:"symbol"`
+	PriceUSD  float64   `json:"price_usd"`
+	Source    string    `gorm:"size:32" json:"source"`
+	CreatedAt time.Time `gorm:"index:idx_symbol_time" json:"created_at"`
+}
+
+// RateCandle is an OHLC candle that snapshots older than the raw retention are
+// downsampled into
+type RateCandle struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Symbol     string    `gorm:"size:16;uniqueIndex:idx_candle" json:"symbol"`
+	Resolution string    `gorm:"size:8;uniqueIndex:idx_candle" json:"resolution"` // 1h, 1d
+	Start      time.Time `gorm:"uniqueIndex:idx_candle" json:"start"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Samples    int       `json:"samples"` // Snapshots aggregated into the candle
+}
+
+// AlertRule notifies a user when a currency's price crosses a threshold
+type AlertRule struct {
+	gorm.Model
+	UserID          uint       `gorm:"index" json:"user_id"`
+	Symbol          string     `gorm:"size:16" json:"symbol"`
+	Currency        string     `gorm:"size:8" json:"currency"`   // Currency the threshold is in
+	Comparison      string     `gorm:"size:8" json:"comparison"` // above, below
+	Threshold       float64    `json:"threshold"`
+	Recurring       bool       `json:"recurring"`           // Re-arms once the price is back on the other side
+	Active          bool       `gorm:"index" json:"active"` // One-shot rules turn inactive after firing
+	Armed           bool       `json:"armed"`               // Fires on the next crossing
+	WebhookURL      string     `gorm:"size:512" json:"webhook_url"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+}
+
+// Notification is a message in a user's in-app inbox
+type Notification struct {
+	gorm.Model
+	UserID uint       `gorm:"index" json:"user_id"`
+	RuleID uint       `json:"rule_id"`
+	Title  string     `json:"title"`
+	Body   string     `gorm:"type:text" json:"body"`
+	ReadAt *time.Time `json:"read_at"`
+}
+
+// LedgerAccount holds money in the double-entry ledger. Balance is a snapshot
+// of the sum of its postings, updated in the same transaction as they are.
+type LedgerAccount struct {
+	gorm.Model
+	Code     string `gorm:"size:64;uniqueIndex" json:"code"` // user:<id>, or system:<name>
+	UserID   *uint  `gorm:"index" json:"user_id"`
+	Currency string `gorm:"size:8" json:"currency"`
+	Balance  int64  `json:"balance"` // Minor units
+}
+
+// JournalEntry groups the postings of one transfer, which sum to zero
+type JournalEntry struct {
+	gorm.Model
+	Type        string    `gorm:"size:32;index" json:"type"` // opening, deposit, purchase
+	Reference   string    `gorm:"size:64;index" json:"reference"`
+	Description string    `json:"description"`
+	Postings    []Posting `gorm:"foreignKey:EntryID" json:"postings"`
+}
+
+// Posting moves Amount minor units into (positive) or out of (negative) an account
+type Posting struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	EntryID   uint      `gorm:"index" json:"entry_id"`
+	AccountID uint      `gorm:"index" json:"account_id"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key, replayed when the same key is sent again
+type IdempotencyRecord struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_user_key" json:"user_id"`
+	Key         string    `gorm:"size:128;uniqueIndex:idx_user_key" json:"key"`
+	Route       string    `gorm:"size:255" json:"route"`       // Method and path the key was first used with
+	RequestHash string    `gorm:"size:64" json:"request_hash"` // SHA-256 of the body
+	Completed   bool      `json:"completed"`                   // False while the first request is running
+	Status      int       `json:"status"`
+	Body        []byte    `gorm:"type:mediumblob" json:"-"`
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DepositIntent is a deposit waiting on a payment provider. The wallet is
+// credited only once the provider confirms the payment.
+type DepositIntent struct {
+	gorm.Model
+	UserID        uint          `gorm:"index" json:"user_id"`
+	Reference     string        `gorm:"size:64;uniqueIndex" json:"reference"` // Ours, sent to the provider
+	Amount        money.Decimal `gorm:"type:decimal(36,18)" json:"amount"`    // In the wallet currency
+	Currency      string        `gorm:"size:8" json:"currency"`
+	Status        string        `gorm:"size:16;index" json:"status"` // pending, succeeded, failed, expired
+	Provider      string        `gorm:"size:32" json:"provider"`
+	ProviderRef   string        `gorm:"size:128;index" json:"provider_ref"`
+	RedirectURL   string        `gorm:"size:1024" json:"redirect_url"`
+	QRPayload     string        `gorm:"type:text" json:"qr_payload"`
+	FailureReason string        `json:"failure_reason"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	CompletedAt   *time.Time    `json:"completed_at"`
 }
@@ -0,0 +1,275 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places a Decimal keeps, enough for wei.
+const Scale = 18
+
+var (
+	ErrInvalidDecimal = errors.New("invalid decimal")
+	ErrTooPrecise     = fmt.Errorf("more than %d decimal places", Scale)
+
+	scaleFactor = new(big.Int).Exp(big.NewInt(10), big.NewInt(Scale), nil)
+)
+
+// Decimal is an exact fixed-point number with Scale decimal places. The zero
+// value is 0. Decimals are immutable; every operation returns a new one.
+// They are stored as DECIMAL(36,18) and encoded in JSON as strings.
+type Decimal struct {
+	units *big.Int // Value * 10^Scale, nil for zero
+}
+
+func (d Decimal) int() *big.Int {
+	if d.units == nil {
+		return new(big.Int)
+	}
+	return d.units
+}
+
+// Zero is the zero Decimal.
+var Zero = Decimal{}
+
+// NewFromInt returns n as a Decimal.
+func NewFromInt(n int64) Decimal {
+	return Decimal{new(big.Int).Mul(big.NewInt(n), scaleFactor)}
+}
+
+// NewFromFloat returns the shortest decimal that reads back as f, so 0.1
+// becomes exactly 0.1 rather than the nearest binary fraction. It is meant for
+// values that were decimals before being stored as floats.
+func NewFromFloat(f float64) Decimal {
+	d, _ := parse(strconv.FormatFloat(f, 'f', -1, 64), true)
+	return d
+}
+
+// Parse reads a decimal such as "-12.345". It fails on more than Scale
+// decimal places rather than rounding them away.
+func Parse(s string) (Decimal, error) {
+	return parse(s, false)
+}
+
+// MustParse is Parse for constants; it panics on invalid input.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func parse(s string, round bool) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) {
+		return Zero, ErrInvalidDecimal
+	}
+
+	roundUp := false
+	if len(frac) > Scale {
+		if !round {
+			return Zero, ErrTooPrecise
+		}
+		roundUp = frac[Scale] >= '5'
+		frac = frac[:Scale]
+	}
+	units, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", Scale-len(frac)), 10)
+	if !ok {
+		return Zero, ErrInvalidDecimal
+	}
+	if roundUp {
+		units.Add(units, big.NewInt(1))
+	}
+	if neg {
+		units.Neg(units)
+	}
+	return Decimal{units}, nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromMinor returns units / 10^places, e.g. cents to an amount with places 2.
+func FromMinor(units int64, places int) Decimal {
+	n := new(big.Int).Mul(big.NewInt(units), pow10(Scale-places))
+	return Decimal{n}
+}
+
+// Minor returns the value in units of 10^-places, rounded half away from zero.
+func (d Decimal) Minor(places int) int64 {
+	return quoRound(d.int(), pow10(Scale-places)).Int64()
+}
+
+func (d Decimal) Add(o Decimal) Decimal { return Decimal{new(big.Int).Add(d.int(), o.int())} }
+func (d Decimal) Sub(o Decimal) Decimal { return Decimal{new(big.Int).Sub(d.int(), o.int())} }
+func (d Decimal) Neg() Decimal          { return Decimal{new(big.Int).Neg(d.int())} }
+
+// Mul returns d * o rounded half away from zero to Scale places.
+func (d Decimal) Mul(o Decimal) Decimal {
+	p := new(big.Int).Mul(d.int(), o.int())
+	return Decimal{quoRound(p, scaleFactor)}
+}
+
+// Div returns d / o rounded half away from zero to Scale places. It panics
+// if o is zero.
+func (d Decimal) Div(o Decimal) Decimal {
+	n := new(big.Int).Mul(d.int(), scaleFactor)
+	q := quoRound(new(big.Int).Abs(n), new(big.Int).Abs(o.int()))
+	if n.Sign()*o.Sign() < 0 {
+		q.Neg(q)
+	}
+	return Decimal{q}
+}
+
+// Round rounds half away from zero to the given number of decimal places.
+func (d Decimal) Round(places int) Decimal {
+	if places >= Scale {
+		return d
+	}
+	f := pow10(Scale - places)
+	return Decimal{new(big.Int).Mul(quoRound(d.int(), f), f)}
+}
+
+func (d Decimal) Cmp(o Decimal) int       { return d.int().Cmp(o.int()) }
+func (d Decimal) Equal(o Decimal) bool    { return d.Cmp(o) == 0 }
+func (d Decimal) LessThan(o Decimal) bool { return d.Cmp(o) < 0 }
+func (d Decimal) Sign() int               { return d.int().Sign() }
+func (d Decimal) IsZero() bool            { return d.Sign() == 0 }
+
+// Float64 returns the nearest float64, for display and scoring only.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String formats d without trailing zeros, e.g. "12.5".
+func (d Decimal) String() string {
+	n := d.int()
+	s := new(big.Int).Abs(n).String()
+	if len(s) <= Scale {
+		s = strings.Repeat("0", Scale-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-Scale], strings.TrimRight(s[len(s)-Scale:], "0")
+	if n.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+// StringFixed formats d rounded to exactly places decimal places.
+func (d Decimal) StringFixed(places int) string {
+	s := d.Round(places).String()
+	if places <= 0 {
+		return s
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	return whole + "." + frac + strings.Repeat("0", places-len(frac))
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON accepts strings and bare numbers. Numbers are read from their
+// text, so they are exact too.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*d = Zero
+		return nil
+	}
+	s := string(data)
+	if unq, err := strconv.Unquote(s); err == nil {
+		s = unq
+	} else if strings.ContainsAny(s, "eE") {
+		// Exponent notation from a JSON number
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return ErrInvalidDecimal
+		}
+		*d = NewFromFloat(f)
+		return nil
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d *Decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Zero
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	case float64:
+		*d = NewFromFloat(v)
+	case float32:
+		*d = NewFromFloat(float64(v))
+	case int64:
+		*d = NewFromInt(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", value)
+	}
+	return nil
+}
+
+func (d *Decimal) scanString(s string) error {
+	v, err := parse(s, true)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// GormDataType makes GORM create DECIMAL(36,18) columns.
+func (Decimal) GormDataType() string {
+	return "decimal(36,18)"
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// quoRound returns n / d rounded half away from zero.
+func quoRound(n, d *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(d) >= 0 {
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
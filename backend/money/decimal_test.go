@@ -0,0 +1,227 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"0", "0", nil},
+		{"12.50", "12.5", nil},
+		{"-12.345", "-12.345", nil},
+		{"+7", "7", nil},
+		{".5", "0.5", nil},
+		{"5.", "5", nil},
+		{" 1.25 ", "1.25", nil},
+		{"0.000000000000000001", "0.000000000000000001", nil},
+		{"-0.000000000000000001", "-0.000000000000000001", nil},
+		{"123456789012345678.123456789012345678", "123456789012345678.123456789012345678", nil},
+		{"0.0000000000000000001", "", ErrTooPrecise},
+		{"", "", ErrInvalidDecimal},
+		{"-", "", ErrInvalidDecimal},
+		{"1.2.3", "", ErrInvalidDecimal},
+		{"1e5", "", ErrInvalidDecimal},
+		{"abc", "", ErrInvalidDecimal},
+	}
+	for _, tt := range tests {
+		d, err := Parse(tt.in)
+		if err != tt.err {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && d.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, d, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"1.234", 2, "1.23"},
+		{"1.235", 2, "1.24"},
+		{"-1.235", 2, "-1.24"}, // Half away from zero
+		{"-1.234", 2, "-1.23"},
+		{"0.5", 0, "1"},
+		{"-0.5", 0, "-1"},
+		{"0.4999", 0, "0"},
+		{"1.999", 2, "2"},
+		{"0.000000000000000001", 18, "0.000000000000000001"},
+		{"0.000000000000000005", 17, "0.00000000000000001"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.in).Round(tt.places).String(); got != tt.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestMinor(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int
+		want   int64
+	}{
+		{"12.34", 2, 1234},
+		{"12.345", 2, 1235},
+		{"-12.345", 2, -1235},
+		{"0.004", 2, 0},
+		{"1", 8, 100000000},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.in).Minor(tt.places); got != tt.want {
+			t.Errorf("Minor(%s, %d) = %d, want %d", tt.in, tt.places, got, tt.want)
+		}
+		if tt.in == "12.34" && !FromMinor(tt.want, tt.places).Equal(MustParse(tt.in)) {
+			t.Errorf("FromMinor(%d, %d) does not round trip", tt.want, tt.places)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := MustParse("0.1"), MustParse("0.2")
+	if got := a.Add(b); !got.Equal(MustParse("0.3")) {
+		t.Errorf("0.1 + 0.2 = %s, want 0.3", got)
+	}
+	if got := a.Sub(b); got.String() != "-0.1" || got.Sign() >= 0 {
+		t.Errorf("0.1 - 0.2 = %s, want -0.1", got)
+	}
+	if got := MustParse("-2.5").Neg(); got.String() != "2.5" {
+		t.Errorf("-(-2.5) = %s, want 2.5", got)
+	}
+	if got := MustParse("1.5").Mul(MustParse("-3")); got.String() != "-4.5" {
+		t.Errorf("1.5 * -3 = %s, want -4.5", got)
+	}
+	// Products are rounded back to 18 places
+	if got := MustParse("0.000000000000000001").Mul(MustParse("0.5")); got.String() != "0.000000000000000001" {
+		t.Errorf("1e-18 * 0.5 = %s, want 1e-18", got)
+	}
+	if !Zero.IsZero() || Zero.String() != "0" {
+		t.Errorf("Zero = %s", Zero)
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"1", "4", "0.25"},
+		{"7.1", "7.1", "1"},
+		{"1", "3", "0.333333333333333333"},
+		{"2", "3", "0.666666666666666667"},
+		{"-2", "3", "-0.666666666666666667"},
+		{"2", "-3", "-0.666666666666666667"},
+		{"-1", "-8", "0.125"},
+		{"0", "5", "0"},
+		{"65000.12", "0.14", "464286.571428571428571429"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.a).Div(MustParse(tt.b)); got.String() != tt.want {
+			t.Errorf("%s / %s = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNewFromFloat(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0.1, "0.1"},
+		{-19.99, "-19.99"},
+		{100, "100"},
+		{1e-19, "0"},
+	}
+	for _, tt := range tests {
+		if got := NewFromFloat(tt.in).String(); got != tt.want {
+			t.Errorf("NewFromFloat(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStringFixed(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"12.5", 2, "12.50"},
+		{"-0.005", 2, "-0.01"},
+		{"3", 2, "3.00"},
+		{"3.7", 0, "4"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.in).StringFixed(tt.places); got != tt.want {
+			t.Errorf("StringFixed(%s, %d) = %s, want %s", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, "0"},
+		{[]byte("12.340000000000000000"), "12.34"},
+		{"-0.5", "-0.5"},
+		{int64(-7), "-7"},
+		{0.1, "0.1"},
+		{[]byte("0.0000000000000000005"), "0.000000000000000001"}, // Wider columns are rounded
+	}
+	for _, tt := range tests {
+		var d Decimal
+		if err := d.Scan(tt.in); err != nil {
+			t.Errorf("Scan(%v) error = %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.want {
+			t.Errorf("Scan(%v) = %s, want %s", tt.in, d, tt.want)
+		}
+	}
+	var d Decimal
+	if err := d.Scan(true); err == nil {
+		t.Error("Scan(bool) succeeded, want an error")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"12.5"`, "12.5"},
+		{`12.5`, "12.5"},
+		{`-0.1`, "-0.1"},
+		{`1e2`, "100"},
+		{`null`, "0"},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		if err := json.Unmarshal([]byte(tt.in), &d); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, d, tt.want)
+		}
+	}
+
+	out, err := json.Marshal(struct{ A Decimal }{MustParse("-1.50")})
+	if err != nil || string(out) != `{"A":"-1.5"}` {
+		t.Errorf("Marshal = %s, %v", out, err)
+	}
+	var d Decimal
+	if err := json.Unmarshal([]byte(`"1.2.3"`), &d); err == nil {
+		t.Error("Unmarshal of an invalid decimal succeeded")
+	}
+}
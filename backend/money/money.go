@@ -0,0 +1,48 @@
+package money
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Money is an amount in a currency. Arithmetic between different currencies
+// fails instead of silently mixing them.
+type Money struct {
+	Amount   Decimal `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func New(amount Decimal, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{m.Amount.Add(o.Amount), m.Currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{m.Amount.Sub(o.Amount), m.Currency}, nil
+}
+
+// Convert returns m in another currency, rate being units of currency per
+// unit of m's currency.
+func (m Money) Convert(rate Decimal, currency string) Money {
+	return New(m.Amount.Mul(rate), currency)
+}
+
+// Round rounds the amount to the given number of decimal places.
+func (m Money) Round(places int) Money {
+	return Money{m.Amount.Round(places), m.Currency}
+}
+
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
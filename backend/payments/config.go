@@ -0,0 +1,56 @@
+package payments
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// Provider deposits are paid through, deposits are disabled when unset. Only
+	// "mock" is built in, it confirms payments on request and is refused
+	// unless MockEnabled is set.
+	ProviderName = ""
+	MockEnabled  = false // Development only, lets users mark their own deposits paid
+
+	// WebhookSecret signs provider callbacks, required for real providers. The
+	// mock makes up a random one when unset, it signs and verifies in process.
+	WebhookSecret    = ""
+	WebhookTolerance = 5 * time.Minute // Max age of a signed callback
+
+	IntentTTL         = 30 * time.Minute // How long a deposit intent can be paid
+	ReconcileInterval = time.Minute
+	ReconcileAfter    = 2 * time.Minute // Pending intents this old are checked with the provider
+)
+
+func init() {
+	if v := os.Getenv("PAYMENT_PROVIDER"); v != "" {
+		ProviderName = strings.ToLower(v)
+	}
+	if v := os.Getenv("PAYMENT_MOCK_ENABLED"); v == "true" || v == "1" {
+		MockEnabled = true
+	}
+	if v := os.Getenv("PAYMENT_WEBHOOK_SECRET"); v != "" {
+		WebhookSecret = v
+	}
+	if v := os.Getenv("PAYMENT_WEBHOOK_TOLERANCE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			WebhookTolerance = d
+		}
+	}
+	if v := os.Getenv("PAYMENT_INTENT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			IntentTTL = d
+		}
+	}
+	if v := os.Getenv("PAYMENT_RECONCILE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ReconcileInterval = d
+		}
+	}
+	if v := os.Getenv("PAYMENT_RECONCILE_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ReconcileAfter = d
+		}
+	}
+}
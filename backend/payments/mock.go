@@ -0,0 +1,115 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"coin-wave/models"
+)
+
+// MockProvider is an in-memory gateway for development. Payments stay pending
+// until Complete is called, which produces a signed webhook like a real
+// gateway would send. Its state is lost on restart, after which unfinished
+// intents expire.
+type MockProvider struct {
+	secret string
+
+	mu       sync.Mutex
+	payments map[string]*Event // By intent reference
+}
+
+func NewMockProvider(secret string) *MockProvider {
+	if secret == "" {
+		secret = randomHex(32)
+		log.Println("[Payments] No webhook secret set, using a random one for the mock provider")
+	}
+	return &MockProvider{secret: secret, payments: map[string]*Event{}}
+}
+
+func (p *MockProvider) Name() string { return "mock" }
+
+func (p *MockProvider) CreateCheckout(_ context.Context, intent *models.DepositIntent) (*Checkout, error) {
+	ref := "mock_" + randomHex(12)
+	p.mu.Lock()
+	p.payments[intent.Reference] = &Event{
+		Reference:   intent.Reference,
+		ProviderRef: ref,
+		Status:      StatusPending,
+		Amount:      intent.Amount,
+		Currency:    intent.Currency,
+	}
+	p.mu.Unlock()
+
+	// There is no hosted page to redirect to, only a QR payload
+	q := url.Values{"ref": {ref}, "amount": {intent.Amount.String()}, "currency": {intent.Currency}}
+	return &Checkout{ProviderRef: ref, QRPayload: "coinwave-mock://pay?" + q.Encode()}, nil
+}
+
+func (p *MockProvider) ParseWebhook(body []byte, header http.Header) (*Event, error) {
+	if err := VerifySignature(p.secret, header.Get(SignatureHeader), body); err != nil {
+		return nil, err
+	}
+	var ev Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, fmt.Errorf("decode webhook: %w", err)
+	}
+	return &ev, nil
+}
+
+func (p *MockProvider) Status(_ context.Context, intent *models.DepositIntent) (*Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev, ok := p.payments[intent.Reference]
+	if !ok {
+		return &Event{Reference: intent.Reference, ProviderRef: intent.ProviderRef, Status: StatusPending}, nil
+	}
+	copied := *ev
+	return &copied, nil
+}
+
+// Complete settles a pending payment as succeeded or failed and returns the
+// webhook body and headers the gateway would send for it.
+func (p *MockProvider) Complete(reference, status string) ([]byte, http.Header, error) {
+	if status != StatusSucceeded && status != StatusFailed {
+		return nil, nil, fmt.Errorf("invalid status %q", status)
+	}
+	p.mu.Lock()
+	ev, ok := p.payments[reference]
+	if ok && ev.Status == StatusPending {
+		ev.Status = status
+		if status == StatusFailed {
+			ev.Reason = "Declined by the mock provider"
+		}
+	}
+	var copied Event
+	if ok {
+		copied = *ev
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, nil, ErrUnknownIntent
+	}
+
+	body, err := json.Marshal(copied)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(SignatureHeader, Sign(p.secret, body, time.Now()))
+	return body, header, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
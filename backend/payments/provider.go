@@ -0,0 +1,76 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"coin-wave/models"
+	"coin-wave/money"
+)
+
+// Deposit intent states
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusExpired   = "expired"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnknownIntent    = errors.New("unknown deposit intent")
+	ErrAmountMismatch   = errors.New("paid amount does not match the intent")
+)
+
+// Checkout tells the user where to pay an intent: a page to redirect to, a
+// payload to show as a QR code, or both.
+type Checkout struct {
+	ProviderRef string
+	RedirectURL string
+	QRPayload   string
+}
+
+// Event is the provider's view of a payment, from a webhook or a status check.
+type Event struct {
+	Reference   string        `json:"reference"` // DepositIntent.Reference
+	ProviderRef string        `json:"provider_ref"`
+	Status      string        `json:"status"` // One of the intent states
+	Amount      money.Decimal `json:"amount"`
+	Currency    string        `json:"currency"`
+	Reason      string        `json:"reason,omitempty"` // Why a payment failed
+}
+
+// Provider is a payment gateway.
+type Provider interface {
+	Name() string
+	// CreateCheckout starts the payment of an intent.
+	CreateCheckout(ctx context.Context, intent *models.DepositIntent) (*Checkout, error)
+	// ParseWebhook verifies a callback and decodes its event, returning
+	// ErrInvalidSignature when it was not sent by the provider.
+	ParseWebhook(body []byte, header http.Header) (*Event, error)
+	// Status asks for the state of an intent's payment, for intents whose
+	// webhook never arrived.
+	Status(ctx context.Context, intent *models.DepositIntent) (*Event, error)
+}
+
+// NewProvider returns the configured Provider, or nil when PAYMENT_PROVIDER
+// is not set and deposits are disabled.
+func NewProvider() (Provider, error) {
+	switch ProviderName {
+	case "":
+		return nil, nil
+	case "mock":
+		if !MockEnabled {
+			return nil, errors.New("the mock payment provider requires PAYMENT_MOCK_ENABLED=true")
+		}
+		return NewMockProvider(WebhookSecret), nil
+	}
+
+	// Real providers must not accept unsigned callbacks
+	if WebhookSecret == "" {
+		return nil, fmt.Errorf("PAYMENT_WEBHOOK_SECRET is required for payment provider %q", ProviderName)
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", ProviderName)
+}
@@ -0,0 +1,223 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"coin-wave/ledger"
+	"coin-wave/models"
+	"coin-wave/money"
+	"coin-wave/rates"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Service runs deposits through a payment provider. A deposit starts as a
+// pending intent and the wallet is credited only when the provider confirms
+// the payment, by webhook or, failing that, by reconciliation.
+type Service struct {
+	db       *gorm.DB
+	provider Provider
+}
+
+func NewService(db *gorm.DB, provider Provider) *Service {
+	return &Service{db: db, provider: provider}
+}
+
+func (s *Service) Provider() Provider {
+	return s.provider
+}
+
+// Start reconciles every ReconcileInterval until ctx is cancelled.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(ReconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Reconcile(ctx); err != nil {
+					log.Printf("[Payments] Reconcile failed: %v", err)
+				}
+			}
+		}
+	}()
+	log.Printf("[Payments] Using provider %q, reconciling every %s", s.provider.Name(), ReconcileInterval)
+}
+
+// CreateIntent opens a deposit of amount in the wallet currency and starts
+// its checkout with the provider.
+func (s *Service) CreateIntent(ctx context.Context, userID uint, amount money.Decimal) (*models.DepositIntent, error) {
+	intent := &models.DepositIntent{
+		UserID:    userID,
+		Reference: "dep_" + randomHex(12),
+		Amount:    amount,
+		Currency:  rates.WalletCurrency,
+		Status:    StatusPending,
+		Provider:  s.provider.Name(),
+		ExpiresAt: time.Now().Add(IntentTTL),
+	}
+	if err := s.db.Create(intent).Error; err != nil {
+		return nil, err
+	}
+
+	checkout, err := s.provider.CreateCheckout(ctx, intent)
+	if err != nil {
+		s.db.Model(intent).Updates(map[string]interface{}{"status": StatusFailed, "failure_reason": "Checkout failed"})
+		return nil, fmt.Errorf("create checkout: %w", err)
+	}
+	intent.ProviderRef = checkout.ProviderRef
+	intent.RedirectURL = checkout.RedirectURL
+	intent.QRPayload = checkout.QRPayload
+	err = s.db.Model(intent).Select("provider_ref", "redirect_url", "qr_payload").Updates(intent).Error
+	if err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// HandleWebhook verifies a provider callback and applies its event.
+func (s *Service) HandleWebhook(body []byte, header http.Header) (*models.DepositIntent, error) {
+	ev, err := s.provider.ParseWebhook(body, header)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ev)
+}
+
+// apply moves an intent to the event's state, crediting the wallet when the
+// payment succeeded. Events for intents that are already settled are ignored,
+// as providers deliver webhooks at least once. A success for an expired
+// intent still credits it, the user has paid.
+func (s *Service) apply(ev *Event) (*models.DepositIntent, error) {
+	if ev.Status != StatusSucceeded && ev.Status != StatusFailed {
+		return nil, nil
+	}
+
+	var intent models.DepositIntent
+	mismatch := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", ev.Reference).First(&intent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownIntent
+		}
+		if err != nil {
+			return err
+		}
+		changed, paid, err := transition(&intent, ev, time.Now())
+		if !changed {
+			return nil
+		}
+		// The failed state of a mismatch must be committed, so it is
+		// reported after the transaction
+		mismatch = errors.Is(err, ErrAmountMismatch)
+		if err := tx.Save(&intent).Error; err != nil {
+			return err
+		}
+		if paid {
+			return credit(tx, &intent)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mismatch {
+		// The intent is marked failed, the payment needs a manual refund
+		log.Printf("[Payments] Intent %s: %v", intent.Reference, ErrAmountMismatch)
+		return &intent, ErrAmountMismatch
+	}
+	return &intent, nil
+}
+
+// transition moves intent to the state of ev in memory. It reports whether
+// the intent changed and whether it is now paid and must be credited. An
+// event for another amount or currency fails the intent, never credit more or
+// less than was asked for, and returns ErrAmountMismatch.
+func transition(intent *models.DepositIntent, ev *Event, now time.Time) (changed, paid bool, err error) {
+	if intent.Status != StatusPending && !(intent.Status == StatusExpired && ev.Status == StatusSucceeded) {
+		return false, false, nil
+	}
+
+	intent.CompletedAt = &now
+	if ev.ProviderRef != "" {
+		intent.ProviderRef = ev.ProviderRef
+	}
+	switch {
+	case ev.Status == StatusFailed:
+		intent.Status = StatusFailed
+		intent.FailureReason = ev.Reason
+		return true, false, nil
+	case ev.Currency != intent.Currency || !ev.Amount.Equal(intent.Amount):
+		intent.Status = StatusFailed
+		intent.FailureReason = fmt.Sprintf("Paid %s %s", ev.Amount, ev.Currency)
+		return true, false, ErrAmountMismatch
+	}
+	intent.Status = StatusSucceeded
+	return true, true, nil
+}
+
+// credit moves a paid intent's amount from the deposits account into the
+// user's wallet.
+func credit(tx *gorm.DB, intent *models.DepositIntent) error {
+	wallet, err := ledger.UserAccount(tx, intent.UserID)
+	if err != nil {
+		return err
+	}
+	deposits, err := ledger.SystemAccount(tx, ledger.AccountDeposits)
+	if err != nil {
+		return err
+	}
+	reference := fmt.Sprintf("deposit:%d", intent.ID)
+	if _, err := ledger.Transfer(tx, "deposit", reference, "User deposit", deposits.ID, wallet.ID, ledger.ToMinor(intent.Amount)); err != nil {
+		return err
+	}
+
+	walletLog := models.WalletLog{
+		UserID:      intent.UserID,
+		Amount:      intent.Amount,
+		Currency:    intent.Currency,
+		Type:        "deposit",
+		Description: "User deposit",
+	}
+	return tx.Create(&walletLog).Error
+}
+
+// Reconcile asks the provider about intents pending for longer than
+// ReconcileAfter, applying the payments it settled and expiring the intents
+// that were never paid.
+func (s *Service) Reconcile(ctx context.Context) error {
+	var batch []models.DepositIntent
+	return s.db.Where("status = ? AND created_at < ?", StatusPending, time.Now().Add(-ReconcileAfter)).
+		FindInBatches(&batch, 100, func(_ *gorm.DB, _ int) error {
+			for i := range batch {
+				intent := &batch[i]
+				ev, err := s.provider.Status(ctx, intent)
+				if err != nil {
+					log.Printf("[Payments] Status of intent %s: %v", intent.Reference, err)
+					continue
+				}
+				if ev.Status == StatusSucceeded || ev.Status == StatusFailed {
+					if _, err := s.apply(ev); err != nil {
+						log.Printf("[Payments] Apply intent %s: %v", intent.Reference, err)
+					}
+					continue
+				}
+				if time.Now().After(intent.ExpiresAt) {
+					err := s.db.Model(&models.DepositIntent{}).Where("id = ? AND status = ?", intent.ID, StatusPending).
+						Updates(map[string]interface{}{"status": StatusExpired, "completed_at": time.Now()}).Error
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return ctx.Err()
+		}).Error
+}
@@ -0,0 +1,66 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"coin-wave/models"
+	"coin-wave/money"
+)
+
+func newTestIntent() *models.DepositIntent {
+	amount, _ := money.Parse("25.50")
+	return &models.DepositIntent{
+		Reference: "dep_test",
+		Amount:    amount,
+		Currency:  "CNY",
+		Status:    StatusPending,
+		Provider:  "mock",
+	}
+}
+
+func TestTransition(t *testing.T) {
+	paid, _ := money.Parse("25.50")
+	short, _ := money.Parse("25.49")
+
+	tests := []struct {
+		name        string
+		status      string // Of the intent
+		event       Event
+		wantChanged bool
+		wantPaid    bool
+		wantErr     error
+		wantStatus  string
+	}{
+		{"paid", StatusPending, Event{Status: StatusSucceeded, Amount: paid, Currency: "CNY", ProviderRef: "mock_1"}, true, true, nil, StatusSucceeded},
+		{"declined", StatusPending, Event{Status: StatusFailed, Reason: "Declined"}, true, false, nil, StatusFailed},
+		{"short payment", StatusPending, Event{Status: StatusSucceeded, Amount: short, Currency: "CNY"}, true, false, ErrAmountMismatch, StatusFailed},
+		{"other currency", StatusPending, Event{Status: StatusSucceeded, Amount: paid, Currency: "USD"}, true, false, ErrAmountMismatch, StatusFailed},
+		{"paid after expiry", StatusExpired, Event{Status: StatusSucceeded, Amount: paid, Currency: "CNY"}, true, true, nil, StatusSucceeded},
+		{"declined after expiry", StatusExpired, Event{Status: StatusFailed}, false, false, nil, StatusExpired},
+		{"redelivered success", StatusSucceeded, Event{Status: StatusSucceeded, Amount: paid, Currency: "CNY"}, false, false, nil, StatusSucceeded},
+		{"failure after success", StatusSucceeded, Event{Status: StatusFailed}, false, false, nil, StatusSucceeded},
+		{"success after failure", StatusFailed, Event{Status: StatusSucceeded, Amount: paid, Currency: "CNY"}, false, false, nil, StatusFailed},
+	}
+	now := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent := newTestIntent()
+			intent.Status = tt.status
+			changed, paid, err := transition(intent, &tt.event, now)
+			if changed != tt.wantChanged || paid != tt.wantPaid || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("transition() = %v, %v, %v, want %v, %v, %v", changed, paid, err, tt.wantChanged, tt.wantPaid, tt.wantErr)
+			}
+			if intent.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", intent.Status, tt.wantStatus)
+			}
+			if changed != (intent.CompletedAt != nil) {
+				t.Errorf("CompletedAt = %v with changed = %v", intent.CompletedAt, changed)
+			}
+			if tt.event.ProviderRef != "" && changed && intent.ProviderRef != tt.event.ProviderRef {
+				t.Errorf("ProviderRef = %q, want %q", intent.ProviderRef, tt.event.ProviderRef)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"reference":"dep_1","status":"succeeded"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		ok     bool
+	}{
+		{"valid", Sign(secret, body, now), body, true},
+		{"within tolerance", Sign(secret, body, now.Add(-WebhookTolerance+time.Minute)), body, true},
+		{"clock skew within tolerance", Sign(secret, body, now.Add(WebhookTolerance-time.Minute)), body, true},
+		{"too old", Sign(secret, body, now.Add(-WebhookTolerance-time.Minute)), body, false},
+		{"too far ahead", Sign(secret, body, now.Add(WebhookTolerance+time.Minute)), body, false},
+		{"other secret", Sign("whsec_other", body, now), body, false},
+		{"tampered body", Sign(secret, body, now), []byte(`{"reference":"dep_1","status":"failed"}`), false},
+		{"extra signature", Sign(secret, body, now) + ",v1=00ff", body, true},
+		{"missing timestamp", "v1=" + strings.Split(Sign(secret, body, now), "v1=")[1], body, false},
+		{"missing signature", "t=" + strings.TrimPrefix(strings.Split(Sign(secret, body, now), ",")[0], "t="), body, false},
+		{"empty", "", body, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(secret, tt.header, tt.body)
+			if tt.ok && err != nil {
+				t.Errorf("VerifySignature() = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifySignature() = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestSignatureTimestampIsSigned(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{}`)
+	old := Sign(secret, body, time.Now().Add(-time.Hour))
+
+	// Moving the timestamp of a captured callback forward breaks the HMAC
+	_, sig, _ := strings.Cut(old, ",")
+	replayed := "t=" + strings.TrimPrefix(strings.Split(Sign(secret, body, time.Now()), ",")[0], "t=") + "," + sig
+	if err := VerifySignature(secret, replayed, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySignature() of a re-dated callback = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestMockProviderWebhook(t *testing.T) {
+	p := NewMockProvider("whsec_test")
+	if _, _, err := p.Complete("dep_missing", StatusSucceeded); !errors.Is(err, ErrUnknownIntent) {
+		t.Errorf("Complete() of an unknown intent = %v, want ErrUnknownIntent", err)
+	}
+
+	intent := newTestIntent()
+	if _, err := p.CreateCheckout(context.Background(), intent); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Complete(intent.Reference, "refunded"); err == nil {
+		t.Error("Complete() accepted an invalid status")
+	}
+
+	body, header, err := p.Complete(intent.Reference, StatusSucceeded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := p.ParseWebhook(body, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Reference != intent.Reference || ev.Status != StatusSucceeded || !ev.Amount.Equal(intent.Amount) {
+		t.Errorf("ParseWebhook() = %+v", ev)
+	}
+
+	// Settled payments do not change again
+	body, header, _ = p.Complete(intent.Reference, StatusFailed)
+	if ev, _ := p.ParseWebhook(body, header); ev.Status != StatusSucceeded {
+		t.Errorf("status after a second Complete() = %q, want %q", ev.Status, StatusSucceeded)
+	}
+
+	if _, err := p.ParseWebhook(body, http.Header{}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseWebhook() without a signature = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,320 @@
+package rag
+
+import (
+	"log"
+	"strings"
+)
+
+// TextChunk is a slice of an article with its rune offsets
+type TextChunk struct {
+	Content    string
+	Start      int
+	End        int
+	Breadcrumb string // Heading path of the chunk, e.g. "Setup > Docker"
+}
+
+// Chunker splits article content into chunks for embedding.
+type Chunker interface {
+	Chunk(text string) []TextChunk
+}
+
+// NewChunker returns the chunker for a strategy: "markdown" (default) or "fixed".
+func NewChunker(strategy string) Chunker {
+	switch strategy {
+	case "fixed":
+		return FixedChunker{Size: ChunkSize, Overlap: ChunkOverlap}
+	case "", "markdown":
+	default:
+		log.Printf("[RAG] Unknown chunk strategy %q, using markdown", strategy)
+	}
+	return MarkdownChunker{MaxTokens: ChunkMaxTokens, OverlapTokens: ChunkOverlapTokens}
+}
+
+// FixedChunker slices runes into fixed windows, ignoring structure.
+type FixedChunker struct {
+	Size    int // Runes per chunk
+	Overlap int // Runes shared by consecutive chunks
+}
+
+func (f FixedChunker) Chunk(text string) []TextChunk {
+	runes := []rune(text)
+	var chunks []TextChunk
+
+	if len(runes) == 0 {
+		return chunks
+	}
+
+	for i := 0; i < len(runes); i += (f.Size - f.Overlap) {
+		end := i + f.Size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, TextChunk{Content: string(runes[i:end]), Start: i, End: end})
+		if end == len(runes) {
+			break
+		}
+	}
+	return chunks
+}
+
+// MarkdownChunker splits recursively on headings, code fences, paragraphs and
+// sentences (including 。！？), then packs the pieces into chunks of at most
+// MaxTokens estimated tokens. Chunks never cross a heading, and each one
+// carries the heading breadcrumb of its section.
+type MarkdownChunker struct {
+	MaxTokens     int
+	OverlapTokens int // Trailing pieces repeated at the start of the next chunk of the same section
+}
+
+// piece is an atomic span of the text that is never split further.
+type piece struct {
+	start, end int // Rune offsets
+	tokens     int
+	breadcrumb string
+	heading    bool // Starts a new section
+}
+
+type lineSpan struct {
+	start, end int // Rune offsets, end excludes the newline
+}
+
+func (m MarkdownChunker) Chunk(text string) []TextChunk {
+	runes := []rune(text)
+	return m.pack(runes, m.pieces(runes))
+}
+
+func (m MarkdownChunker) pieces(runes []rune) []piece {
+	lines := splitLines(runes)
+	lineText := func(i int) string {
+		return strings.TrimSpace(string(runes[lines[i].start:lines[i].end]))
+	}
+
+	var pieces []piece
+	var headings [6]string
+	breadcrumb := ""
+
+	for i := 0; i < len(lines); {
+		trimmed := lineText(i)
+		switch {
+		case trimmed == "":
+			i++
+
+		case isFence(trimmed):
+			// Code block: up to and including the closing fence
+			fence := trimmed[:3]
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(lineText(j), fence) {
+				j++
+			}
+			if j < len(lines) {
+				j++
+			}
+			pieces = append(pieces, m.codePieces(runes, lines[i:j], breadcrumb)...)
+			i = j
+
+		case headingLevel(trimmed) > 0:
+			level := headingLevel(trimmed)
+			headings[level-1] = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			for k := level; k < len(headings); k++ {
+				headings[k] = ""
+			}
+			var path []string
+			for _, h := range headings {
+				if h != "" {
+					path = append(path, h)
+				}
+			}
+			breadcrumb = strings.Join(path, " > ")
+
+			span := lines[i]
+			pieces = append(pieces, piece{
+				start:      span.start,
+				end:        span.end,
+				tokens:     EstimateTokens(trimmed),
+				breadcrumb: breadcrumb,
+				heading:    true,
+			})
+			i++
+
+		default:
+			// Paragraph: consecutive non-blank lines
+			j := i + 1
+			for j < len(lines) {
+				t := lineText(j)
+				if t == "" || isFence(t) || headingLevel(t) > 0 {
+					break
+				}
+				j++
+			}
+			pieces = append(pieces, m.textPieces(runes, lines[i].start, lines[j-1].end, breadcrumb)...)
+			i = j
+		}
+	}
+	return pieces
+}
+
+// textPieces keeps a paragraph whole if it fits, otherwise splits it into sentences.
+func (m MarkdownChunker) textPieces(runes []rune, start, end int, breadcrumb string) []piece {
+	tokens := EstimateTokens(string(runes[start:end]))
+	if tokens <= m.MaxTokens {
+		return []piece{{start: start, end: end, tokens: tokens, breadcrumb: breadcrumb}}
+	}
+
+	var pieces []piece
+	for _, s := range splitSentences(runes, start, end) {
+		t := EstimateTokens(string(runes[s.start:s.end]))
+		if t <= m.MaxTokens {
+			pieces = append(pieces, piece{start: s.start, end: s.end, tokens: t, breadcrumb: breadcrumb})
+			continue
+		}
+		pieces = append(pieces, m.hardSplit(runes, s.start, s.end, breadcrumb)...)
+	}
+	return pieces
+}
+
+// codePieces keeps a code block whole if it fits, otherwise groups its lines.
+func (m MarkdownChunker) codePieces(runes []rune, lines []lineSpan, breadcrumb string) []piece {
+	start, end := lines[0].start, lines[len(lines)-1].end
+	tokens := EstimateTokens(string(runes[start:end]))
+	if tokens <= m.MaxTokens {
+		return []piece{{start: start, end: end, tokens: tokens, breadcrumb: breadcrumb}}
+	}
+
+	var pieces []piece
+	for _, l := range lines {
+		t := EstimateTokens(string(runes[l.start:l.end]))
+		if t > m.MaxTokens {
+			pieces = append(pieces, m.hardSplit(runes, l.start, l.end, breadcrumb)...)
+			continue
+		}
+		// Merge into the previous group of lines while it fits
+		if n := len(pieces); n > 0 && pieces[n-1].end <= l.start && pieces[n-1].tokens+t <= m.MaxTokens {
+			pieces[n-1].end = l.end
+			pieces[n-1].tokens += t
+			continue
+		}
+		pieces = append(pieces, piece{start: l.start, end: l.end, tokens: t, breadcrumb: breadcrumb})
+	}
+	return pieces
+}
+
+// hardSplit cuts a span into windows of MaxTokens runes. A token is at least
+// one rune, so every window stays within the token budget.
+func (m MarkdownChunker) hardSplit(runes []rune, start, end int, breadcrumb string) []piece {
+	var pieces []piece
+	for i := start; i < end; i += m.MaxTokens {
+		j := i + m.MaxTokens
+		if j > end {
+			j = end
+		}
+		pieces = append(pieces, piece{
+			start:      i,
+			end:        j,
+			tokens:     EstimateTokens(string(runes[i:j])),
+			breadcrumb: breadcrumb,
+		})
+	}
+	return pieces
+}
+
+// pack greedily fills chunks with pieces, starting a new chunk at every heading.
+func (m MarkdownChunker) pack(runes []rune, pieces []piece) []TextChunk {
+	var chunks []TextChunk
+	var cur []piece
+	curTokens := 0
+
+	flush := func() {
+		if len(cur) == 0 {
+			return
+		}
+		start, end := cur[0].start, cur[len(cur)-1].end
+		chunks = append(chunks, TextChunk{
+			Content:    string(runes[start:end]),
+			Start:      start,
+			End:        end,
+			Breadcrumb: cur[len(cur)-1].breadcrumb,
+		})
+	}
+
+	for _, p := range pieces {
+		switch {
+		case p.heading:
+			flush()
+			cur, curTokens = nil, 0
+		case len(cur) > 0 && curTokens+p.tokens > m.MaxTokens:
+			flush()
+			// Carry trailing pieces over as overlap, always leaving room for p
+			var carry []piece
+			carried := 0
+			for k := len(cur) - 1; k > 0; k-- {
+				if carried+cur[k].tokens > m.OverlapTokens || carried+cur[k].tokens+p.tokens > m.MaxTokens {
+					break
+				}
+				carry = append([]piece{cur[k]}, carry...)
+				carried += cur[k].tokens
+			}
+			cur, curTokens = carry, carried
+		}
+		cur = append(cur, p)
+		curTokens += p.tokens
+	}
+	flush()
+	return chunks
+}
+
+func splitLines(runes []rune) []lineSpan {
+	var lines []lineSpan
+	start := 0
+	for i, r := range runes {
+		if r == '\n' {
+			lines = append(lines, lineSpan{start, i})
+			start = i + 1
+		}
+	}
+	if start < len(runes) {
+		lines = append(lines, lineSpan{start, len(runes)})
+	}
+	return lines
+}
+
+// splitSentences splits a span after sentence terminators (。！？；!?; and
+// '.' followed by whitespace) and at line breaks.
+func splitSentences(runes []rune, start, end int) []lineSpan {
+	var sentences []lineSpan
+	from := start
+	for i := start; i < end; i++ {
+		boundary := false
+		switch runes[i] {
+		case '。', '！', '？', '；', '!', '?', ';', '\n':
+			boundary = true
+		case '.':
+			boundary = i+1 == end || runes[i+1] == ' ' || runes[i+1] == '\n'
+		}
+		if boundary {
+			if i+1 > from {
+				sentences = append(sentences, lineSpan{from, i + 1})
+			}
+			from = i + 1
+		}
+	}
+	if from < end {
+		sentences = append(sentences, lineSpan{from, end})
+	}
+	return sentences
+}
+
+func isFence(line string) bool {
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+// headingLevel returns 1-6 for ATX headings ("# Title"), 0 otherwise.
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
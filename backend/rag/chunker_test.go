@@ -0,0 +1,179 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkChunks verifies the invariants every chunker output must hold.
+func checkChunks(t *testing.T, text string, chunks []TextChunk, maxTokens int) {
+	t.Helper()
+	runes := []rune(text)
+	for i, c := range chunks {
+		if c.Start < 0 || c.End > len(runes) || c.Start >= c.End {
+			t.Fatalf("chunk %d has bad offsets [%d, %d) for %d runes", i, c.Start, c.End, len(runes))
+		}
+		if got := string(runes[c.Start:c.End]); got != c.Content {
+			t.Errorf("chunk %d content %q does not match its offsets (%q)", i, c.Content, got)
+		}
+		if maxTokens > 0 {
+			if n := EstimateTokens(c.Content); n > maxTokens {
+				t.Errorf("chunk %d has %d tokens, over the %d budget", i, n, maxTokens)
+			}
+		}
+	}
+}
+
+func TestMarkdownChunkerBreadcrumbs(t *testing.T) {
+	text := "# Setup\nInstall it.\n\n## Docker\nRun compose.\n\n### Ports\nOpen 8080.\n\n# Usage\nLog in."
+	chunks := MarkdownChunker{MaxTokens: 100, OverlapTokens: 10}.Chunk(text)
+	checkChunks(t, text, chunks, 100)
+
+	want := []struct {
+		breadcrumb string
+		contains   string
+	}{
+		{"Setup", "Install it."},
+		{"Setup > Docker", "Run compose."},
+		{"Setup > Docker > Ports", "Open 8080."},
+		{"Usage", "Log in."}, // A same-level heading resets the deeper ones
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want one per section: %+v", len(chunks), chunks)
+	}
+	for i, w := range want {
+		if chunks[i].Breadcrumb != w.breadcrumb {
+			t.Errorf("chunk %d breadcrumb = %q, want %q", i, chunks[i].Breadcrumb, w.breadcrumb)
+		}
+		if !strings.Contains(chunks[i].Content, w.contains) {
+			t.Errorf("chunk %d = %q, want it to contain %q", i, chunks[i].Content, w.contains)
+		}
+		// Chunks never cross a heading: only the first line may be one
+		for _, line := range strings.Split(chunks[i].Content, "\n")[1:] {
+			if headingLevel(strings.TrimSpace(line)) > 0 {
+				t.Errorf("chunk %d crosses heading %q", i, line)
+			}
+		}
+	}
+}
+
+func TestMarkdownChunkerTokenBudget(t *testing.T) {
+	sentence := "The quick brown fox jumps over the lazy dog again and again. "
+	tests := []struct {
+		name      string
+		text      string
+		maxTokens int
+		minChunks int
+	}{
+		{"empty", "", 20, 0},
+		{"blank lines only", "\n\n  \n", 20, 0},
+		{"short paragraph", "Just one line.", 20, 1},
+		{"long paragraph split at sentences", strings.Repeat(sentence, 30), 40, 5},
+		{"CJK sentences", strings.Repeat("比特币价格今天上涨了百分之五。", 20), 30, 5},
+		{"single word longer than the budget", strings.Repeat("a", 500), 20, 5},
+		{"long code block split by lines", "```go\n" + strings.Repeat("fmt.Println(\"hello world\")\n", 40) + "```", 30, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := MarkdownChunker{MaxTokens: tt.maxTokens, OverlapTokens: tt.maxTokens / 4}.Chunk(tt.text)
+			checkChunks(t, tt.text, chunks, tt.maxTokens)
+			if len(chunks) < tt.minChunks {
+				t.Errorf("got %d chunks, want at least %d", len(chunks), tt.minChunks)
+			}
+			if tt.minChunks == 0 && len(chunks) != 0 {
+				t.Errorf("got %d chunks, want none", len(chunks))
+			}
+		})
+	}
+}
+
+func TestMarkdownChunkerKeepsCodeBlocksWhole(t *testing.T) {
+	code := "```bash\n# not a heading\n\ndocker compose up\n```"
+	text := "# Run\nStart it with:\n\n" + code + "\n\nDone."
+	chunks := MarkdownChunker{MaxTokens: 200, OverlapTokens: 0}.Chunk(text)
+	checkChunks(t, text, chunks, 200)
+
+	if len(chunks) != 1 {
+		t.Fatalf("got %d chunks, want 1: %+v", len(chunks), chunks)
+	}
+	if !strings.Contains(chunks[0].Content, code) {
+		t.Errorf("code block was split: %q", chunks[0].Content)
+	}
+	if chunks[0].Breadcrumb != "Run" {
+		t.Errorf("breadcrumb = %q, want the comment in the code not to count as a heading", chunks[0].Breadcrumb)
+	}
+}
+
+func TestMarkdownChunkerOverlap(t *testing.T) {
+	text := strings.Repeat("One short sentence here. ", 40)
+	chunks := MarkdownChunker{MaxTokens: 30, OverlapTokens: 10}.Chunk(text)
+	checkChunks(t, text, chunks, 30)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].Start >= chunks[i-1].End {
+			t.Errorf("chunk %d starts at %d, want it to overlap the previous one ending at %d", i, chunks[i].Start, chunks[i-1].End)
+		}
+		if chunks[i].Start <= chunks[i-1].Start {
+			t.Errorf("chunk %d does not advance: starts at %d after %d", i, chunks[i].Start, chunks[i-1].Start)
+		}
+	}
+
+	// No overlap across sections
+	text = "# A\n" + text + "\n# B\nTail."
+	chunks = MarkdownChunker{MaxTokens: 30, OverlapTokens: 10}.Chunk(text)
+	last := chunks[len(chunks)-1]
+	if last.Breadcrumb != "B" || strings.Contains(last.Content, "sentence") {
+		t.Errorf("last chunk = %+v, want section B alone", last)
+	}
+}
+
+func TestFixedChunker(t *testing.T) {
+	tests := []struct {
+		text      string
+		size      int
+		overlap   int
+		wantStart []int
+	}{
+		{"", 10, 2, nil},
+		{"abcdef", 10, 2, []int{0}},
+		{"abcdefghij", 4, 1, []int{0, 3, 6}},
+		{"比特币以太坊", 4, 0, []int{0, 4}}, // Runes, not bytes
+	}
+	for _, tt := range tests {
+		chunks := FixedChunker{Size: tt.size, Overlap: tt.overlap}.Chunk(tt.text)
+		checkChunks(t, tt.text, chunks, 0)
+		if len(chunks) != len(tt.wantStart) {
+			t.Errorf("Chunk(%q) = %d chunks, want %d", tt.text, len(chunks), len(tt.wantStart))
+			continue
+		}
+		for i, c := range chunks {
+			if c.Start != tt.wantStart[i] {
+				t.Errorf("Chunk(%q)[%d] starts at %d, want %d", tt.text, i, c.Start, tt.wantStart[i])
+			}
+		}
+		if n := len(chunks); n > 0 && chunks[n-1].End != len([]rune(tt.text)) {
+			t.Errorf("Chunk(%q) does not reach the end", tt.text)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"one two", 2},
+		{"比特币", 3},
+		{"BTC比特币", 4},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package rag
+
+import (
+	"regexp"
+	"strconv"
+
+	"coin-wave/models"
+)
+
+// Citation is a retrieved chunk presented to the model as source [Marker].
+type Citation struct {
+	Marker      int     `json:"marker"` // n in the [n] markers of the answer
+	ArticleID   uint    `json:"article_id"`
+	Title       string  `json:"title"`
+	ChunkIndex  int     `json:"chunk_index"`
+	Score       float32 `json:"score"`
+	StartOffset int     `json:"start_offset"` // Rune offsets into the article content
+	EndOffset   int     `json:"end_offset"`
+	Breadcrumb  string  `json:"breadcrumb,omitempty"` // Heading path of the chunk
+	Content     string  `json:"content"`
+	Cited       bool    `json:"cited"` // The answer references this source
+}
+
+// buildCitations numbers search results and attaches article titles and chunk offsets.
+func (s *RagService) buildCitations(results []SearchResult) []Citation {
+	citations := make([]Citation, len(results))
+	if len(results) == 0 {
+		return citations
+	}
+
+	articleIDs := make([]uint, 0, len(results))
+	for _, res := range results {
+		articleIDs = append(articleIDs, uint(res.ArticleID))
+	}
+
+	var articles []models.Article
+	s.db.Select("id, title").Where("id IN ?", articleIDs).Find(&articles)
+	titles := make(map[uint]string, len(articles))
+	for _, a := range articles {
+		titles[a.ID] = a.Title
+	}
+
+	type chunkKey struct {
+		articleID  uint
+		chunkIndex int
+	}
+	var chunks []models.Chunk
+	s.db.Select("article_id, chunk_index, start_offset, end_offset, breadcrumb").Where("article_id IN ?", articleIDs).Find(&chunks)
+	offsets := make(map[chunkKey]models.Chunk, len(chunks))
+	for _, c := range chunks {
+		offsets[chunkKey{c.ArticleID, c.ChunkIndex}] = c
+	}
+
+	for i, res := range results {
+		c := Citation{
+			Marker:     i + 1,
+			ArticleID:  uint(res.ArticleID),
+			Title:      titles[uint(res.ArticleID)],
+			ChunkIndex: int(res.ChunkIndex),
+			Score:      res.Score,
+			Content:    res.Content,
+		}
+		if chunk, ok := offsets[chunkKey{c.ArticleID, c.ChunkIndex}]; ok {
+			c.StartOffset = chunk.StartOffset
+			c.EndOffset = chunk.EndOffset
+			c.Breadcrumb = chunk.Breadcrumb
+		}
+		citations[i] = c
+	}
+	return citations
+}
+
+var citationMarkerRe = regexp.MustCompile(`\[(\d+)\]`)
+
+// CitedMarkers returns the [n] markers found in the answer, in order of first appearance.
+func CitedMarkers(answer string) []int {
+	var markers []int
+	seen := make(map[int]bool)
+	for _, m := range citationMarkerRe.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || seen[n] {
+			continue
+		}
+		seen[n] = true
+		markers = append(markers, n)
+	}
+	return markers
+}
+
+// markCited flags the citations referenced by markers in the answer.
+// Markers that do not match any source are ignored.
+func markCited(answer string, citations []Citation) []Citation {
+	for _, n := range CitedMarkers(answer) {
+		if n >= 1 && n <= len(citations) {
+			citations[n-1].Cited = true
+		}
+	}
+	return citations
+}
+
+// SourceTexts returns the plain chunk contents, as the legacy "sources" field expects.
+func SourceTexts(citations []Citation) []string {
+	texts := make([]string, len(citations))
+	for i, c := range citations {
+		texts[i] = c.Content
+	}
+	return texts
+}
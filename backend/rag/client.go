@@ -1,10 +1,13 @@
 package rag
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -12,6 +15,7 @@ import (
 
 type VolcClient struct {
 	client *resty.Client
+	stream *resty.Client // For ChatStream, without the overall timeout
 }
 
 // Internal structures for Volcengine API
@@ -46,8 +50,19 @@ type ChatMessageInput struct {
 }
 
 type ChatRequest struct {
-	Model string             `json:"model"`
-	Input []ChatMessageInput `json:"input"`
+	Model  string             `json:"model"`
+	Input  []ChatMessageInput `json:"input"`
+	Stream bool               `json:"stream,omitempty"`
+}
+
+// ChatStreamEvent is one server-sent event of a streamed response
+type ChatStreamEvent struct {
+	Type     string `json:"type"` // "response.output_text.delta", "response.completed", "error", ...
+	Delta    string `json:"delta"`
+	Message  string `json:"message"`
+	Response *struct {
+		Error *APIError `json:"error,omitempty"`
+	} `json:"response,omitempty"`
 }
 
 type ChatResponse struct {
@@ -80,6 +95,9 @@ func NewVolcClient() *VolcClient {
 			SetHeader("Authorization", "Bearer "+VolcAuthToken).
 			SetHeader("Content-Type", "application/json").
 			SetTimeout(120 * time.Second), // Increased timeout for RAG
+		stream: newStreamClient().
+			SetHeader("Authorization", "Bearer "+VolcAuthToken).
+			SetHeader("Content-Type", "application/json"),
 	}
 }
 
@@ -151,23 +169,9 @@ func (c *VolcClient) GetEmbeddings(texts []string) ([][]float32, error) {
 }
 
 func (c *VolcClient) Chat(messages []Message) (string, error) {
-	// Convert simple messages to multimodal input format
-	chatInputs := make([]ChatMessageInput, len(messages))
-	for i, msg := range messages {
-		chatInputs[i] = ChatMessageInput{
-			Role: msg.Role,
-			Content: []ChatContentItem{
-				{
-					Type: "input_text", // Note: API expects "input_text" here
-					Text: msg.Content,
-				},
-			},
-		}
-	}
-
 	reqBody := ChatRequest{
 		Model: VolcModelLLM,
-		Input: chatInputs,
+		Input: toChatInputs(messages),
 	}
 
 	var respBody ChatResponse
@@ -197,3 +201,72 @@ func (c *VolcClient) Chat(messages []Message) (string, error) {
 
 	return "", errors.New("no assistant message found in response")
 }
+
+// ChatStream requests a streamed response and forwards text deltas to onDelta.
+// Cancelling ctx aborts the upstream request.
+func (c *VolcClient) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	reqBody := ChatRequest{
+		Model:  VolcModelLLM,
+		Input:  toChatInputs(messages),
+		Stream: true,
+	}
+
+	resp, err := c.stream.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetHeader("Accept", "text/event-stream").
+		SetDoNotParseResponse(true).
+		Post(VolcResponseEndpoint)
+	if err != nil {
+		return "", err
+	}
+	body := withIdleTimeout(resp.RawBody(), StreamIdleTimeout)
+	defer body.Close()
+
+	if resp.IsError() {
+		raw, _ := io.ReadAll(body)
+		return "", fmt.Errorf("api error: %s", string(raw))
+	}
+
+	var answer strings.Builder
+	err = readSSE(body, func(event, data string) error {
+		var ev ChatStreamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return nil // Skip keep-alives and unknown payloads
+		}
+		switch ev.Type {
+		case "response.output_text.delta":
+			answer.WriteString(ev.Delta)
+			return onDelta(ev.Delta)
+		case "error":
+			return fmt.Errorf("api error: %s", ev.Message)
+		case "response.failed":
+			if ev.Response != nil && ev.Response.Error != nil {
+				return fmt.Errorf("api error: %s", ev.Response.Error.Message)
+			}
+			return errors.New("api error: response failed")
+		case "response.completed":
+			return errStreamDone
+		}
+		return nil
+	})
+	return answer.String(), err
+}
+
+func toChatInputs(messages []Message) []ChatMessageInput {
+	// Convert simple messages to multimodal input format
+	chatInputs := make([]ChatMessageInput, len(messages))
+	for i, msg := range messages {
+		chatInputs[i] = ChatMessageInput{
+			Role: msg.Role,
+			Content: []ChatContentItem{
+				{
+					Type: "input_text", // Note: API expects "input_text" here
+					Text: msg.Content,
+				},
+			},
+		}
+	}
+
+	return chatInputs
+}
@@ -2,6 +2,8 @@ package rag
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 var (
@@ -11,6 +13,24 @@ var (
 	VolcModelLLM          = "ep-20260119190028-tmjkb"
 	VolcAuthToken         = "97443eb3-4d60-4738-bf9d-e7bf364566d2" // Default fallback
 
+	// Provider selection: "volc" (default), "openai" or "fake"
+	RagProvider = "volc"
+
+	// OpenAI-compatible provider (OpenAI, Ollama, vLLM, ...)
+	OpenAIBaseURL        = "https://api.openai.com/v1"
+	OpenAIAPIKey         = ""
+	OpenAIModelEmbedding = "text-embedding-3-small"
+	OpenAIModelLLM       = "gpt-4o-mini"
+
+	// Streamed answers have no overall timeout, only a limit on the wait for
+	// the response headers and between two reads
+	StreamIdleTimeout = 60 * time.Second
+
+	// Vector store selection: "milvus" (default) or "local". The local store is
+	// only used when chosen, never as a fallback when Milvus is down.
+	VectorStoreType = "milvus"
+	LocalStorePath  = "./data/vectors.gob"
+
 	MilvusAddress         = "localhost:19530"
 	MilvusCollectionName  = "coinwave_articles"
 	MilvusDim             = 2048
@@ -18,10 +38,67 @@ var (
 
 // Chunking config
 const (
-	ChunkSize    = 800
+	ChunkSize    = 800 // Runes per chunk for the fixed strategy
 	ChunkOverlap = 100
 )
 
+var (
+	ChunkStrategy      = "markdown" // "markdown" (heading/paragraph/sentence aware) or "fixed"
+	ChunkMaxTokens     = 512        // Estimated tokens per chunk for the markdown strategy
+	ChunkOverlapTokens = 64
+)
+
+// Ingestion queue config
+var (
+	IngestConcurrency  = 2                // Parallel ingestion workers
+	IngestMaxAttempts  = 5                // Attempts before a job is dead-lettered
+	IngestRetryBase    = 10 * time.Second // First retry delay, doubled on every attempt
+	IngestRetryMax     = 10 * time.Minute
+	IngestPollInterval = 2 * time.Second
+	IngestJobTimeout   = 10 * time.Minute
+	// Running jobs locked for longer are presumed abandoned by a crashed
+	// replica and requeued. Must exceed IngestJobTimeout.
+	IngestLeaseTimeout = 15 * time.Minute
+)
+
+// Hybrid retrieval config
+var (
+	RagTopK                = 5  // Max chunks given to the model
+	RagCandidates          = 30 // Chunks fetched from each retriever, fused and reranked
+	RagFusionVectorWeight  = 1.0
+	RagFusionKeywordWeight = 1.0  // 0 disables keyword retrieval
+	RagRRFK                = 60.0 // Reciprocal rank fusion constant, larger flattens rank differences
+
+	// Scopes with more articles than this are not listed in the search
+	// filter. Retrievers then fetch RagScopeOversample times more candidates
+	// from all articles and the ones out of scope are dropped.
+	RagScopeMaxArticles = 1000
+	RagScopeOversample  = 4
+)
+
+// Related articles config
+var (
+	// Article embeddings are cached in memory and reloaded this long after
+	// the last load, to catch up on index changes of other replicas that
+	// were missed
+	RelatedVectorsTTL = 5 * time.Minute
+)
+
+// Reranking config
+var (
+	RerankProvider        = "lexical" // "lexical" (local term overlap), "api" or "none"
+	RerankBaseURL         = ""        // Full URL of a /rerank endpoint (Jina, Cohere, TEI, ...)
+	RerankAPIKey          = ""
+	RerankModel           = ""
+	RagContextTokens      = 2000 // Estimated token budget for retrieved context in the prompt
+	RagDuplicateThreshold = 0.8  // Jaccard similarity above which a chunk is a near-duplicate
+)
+
+// Conversation config
+const (
+	HistoryWindow = 6 // Previous messages (user + assistant) included in prompts
+)
+
 func init() {
 	if v := os.Getenv("VOLC_AUTH_TOKEN"); v != "" {
 		VolcAuthToken = v
@@ -29,6 +106,12 @@ func init() {
 	if v := os.Getenv("MILVUS_ADDRESS"); v != "" {
 		MilvusAddress = v
 	}
+	if v := os.Getenv("VECTOR_STORE"); v != "" {
+		VectorStoreType = v
+	}
+	if v := os.Getenv("VECTOR_STORE_PATH"); v != "" {
+		LocalStorePath = v
+	}
 	// Allow overriding other configs if needed
 	if v := os.Getenv("VOLC_MODEL_EMBEDDING"); v != "" {
 		VolcModelEmbedding = v
@@ -36,4 +119,95 @@ func init() {
 	if v := os.Getenv("VOLC_MODEL_LLM"); v != "" {
 		VolcModelLLM = v
 	}
+	if v := os.Getenv("RAG_PROVIDER"); v != "" {
+		RagProvider = v
+	}
+	if v := os.Getenv("OPENAI_BASE_URL"); v != "" {
+		OpenAIBaseURL = v
+	}
+	if v := os.Getenv("OPENAI_API_KEY"); v != "" {
+		OpenAIAPIKey = v
+	}
+	if v := os.Getenv("OPENAI_MODEL_EMBEDDING"); v != "" {
+		OpenAIModelEmbedding = v
+	}
+	if v := os.Getenv("OPENAI_MODEL_LLM"); v != "" {
+		OpenAIModelLLM = v
+	}
+	if v := os.Getenv("RAG_STREAM_IDLE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			StreamIdleTimeout = d
+		}
+	}
+	if v := os.Getenv("CHUNK_STRATEGY"); v != "" {
+		ChunkStrategy = v
+	}
+	if v := os.Getenv("CHUNK_MAX_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			ChunkMaxTokens = n
+		}
+	}
+	if v := os.Getenv("CHUNK_OVERLAP_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			ChunkOverlapTokens = n
+		}
+	}
+	if v := os.Getenv("INGEST_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			IngestConcurrency = n
+		}
+	}
+	if v := os.Getenv("INGEST_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			IngestMaxAttempts = n
+		}
+	}
+	if v := os.Getenv("INGEST_LEASE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > IngestJobTimeout {
+			IngestLeaseTimeout = d
+		}
+	}
+	if v := os.Getenv("RAG_FUSION_VECTOR_WEIGHT"); v != "" {
+		if w, err := strconv.ParseFloat(v, 64); err == nil && w >= 0 {
+			RagFusionVectorWeight = w
+		}
+	}
+	if v := os.Getenv("RAG_FUSION_KEYWORD_WEIGHT"); v != "" {
+		if w, err := strconv.ParseFloat(v, 64); err == nil && w >= 0 {
+			RagFusionKeywordWeight = w
+		}
+	}
+	if v := os.Getenv("RAG_RRF_K"); v != "" {
+		if k, err := strconv.ParseFloat(v, 64); err == nil && k > 0 {
+			RagRRFK = k
+		}
+	}
+	if v := os.Getenv("RELATED_VECTORS_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			RelatedVectorsTTL = d
+		}
+	}
+	if v := os.Getenv("RERANK_PROVIDER"); v != "" {
+		RerankProvider = v
+	}
+	if v := os.Getenv("RERANK_BASE_URL"); v != "" {
+		RerankBaseURL = v
+	}
+	if v := os.Getenv("RERANK_API_KEY"); v != "" {
+		RerankAPIKey = v
+	}
+	if v := os.Getenv("RERANK_MODEL"); v != "" {
+		RerankModel = v
+	}
+	if v := os.Getenv("RAG_CONTEXT_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			RagContextTokens = n
+		}
+	}
+	// Embedding dimension must match the provider's model (e.g. 1536 for text-embedding-3-small)
+	if v := os.Getenv("EMBEDDING_DIM"); v != "" {
+		if dim, err := strconv.Atoi(v); err == nil && dim > 0 {
+			MilvusDim = dim
+		}
+	}
 }
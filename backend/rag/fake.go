@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// FakeProvider is a deterministic in-process Embedder and ChatModel for tests
+// and offline development. Embeddings are hashed bag-of-words vectors, so texts
+// sharing words end up close to each other.
+type FakeProvider struct {
+	dim int
+}
+
+func NewFakeProvider(dim int) *FakeProvider {
+	return &FakeProvider{dim: dim}
+}
+
+func (f *FakeProvider) GetEmbeddings(texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = f.embed(text)
+	}
+	return embeddings, nil
+}
+
+func (f *FakeProvider) embed(text string) []float32 {
+	vec := make([]float32, f.dim)
+	for _, token := range fakeTokens(text) {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		vec[int(h.Sum32())%f.dim] += 1
+	}
+
+	// L2 normalize so distances are comparable between texts of different length
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vec {
+			vec[i] = float32(float64(vec[i]) / norm)
+		}
+	}
+	return vec
+}
+
+// fakeTokens splits on non-alphanumerics and treats every Han character as its own token.
+func fakeTokens(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+func (f *FakeProvider) Chat(messages []Message) (string, error) {
+	if len(messages) == 0 {
+		return "", fmt.Errorf("no messages")
+	}
+	last := []rune(messages[len(messages)-1].Content)
+	if len(last) > 200 {
+		last = last[:200]
+	}
+	return fmt.Sprintf("[fake] %d message(s) received. Last message: %s", len(messages), string(last)), nil
+}
+
+// ChatStream emits the Chat answer word by word.
+func (f *FakeProvider) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	answer, err := f.Chat(messages)
+	if err != nil {
+		return "", err
+	}
+	for _, word := range strings.SplitAfter(answer, " ") {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onDelta(word); err != nil {
+			return "", err
+		}
+	}
+	return answer, nil
+}
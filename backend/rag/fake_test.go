@@ -0,0 +1,124 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFakeProviderEmbeddings(t *testing.T) {
+	f := NewFakeProvider(64)
+	texts := []string{"Bitcoin price rally", "bitcoin PRICE rally!", "Ethereum staking rewards", "比特币", ""}
+	vecs, err := f.GetEmbeddings(texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != len(texts) {
+		t.Fatalf("got %d embeddings for %d texts", len(vecs), len(texts))
+	}
+	for i, v := range vecs {
+		if len(v) != 64 {
+			t.Errorf("embedding %d has %d dimensions, want 64", i, len(v))
+		}
+	}
+
+	tests := []struct {
+		name string
+		i, j int
+		same bool
+	}{
+		{"case and punctuation are ignored", 0, 1, true},
+		{"different words differ", 0, 2, false},
+	}
+	for _, tt := range tests {
+		if same := squaredL2(vecs[tt.i], vecs[tt.j]) < 1e-9; same != tt.same {
+			t.Errorf("%s: embeddings %d and %d equal = %v", tt.name, tt.i, tt.j, same)
+		}
+	}
+
+	// Unit length, except the empty text which has no tokens
+	for i, v := range vecs[:4] {
+		var norm float64
+		for _, x := range v {
+			norm += float64(x) * float64(x)
+		}
+		if math.Abs(norm-1) > 1e-5 {
+			t.Errorf("embedding %d has squared norm %v, want 1", i, norm)
+		}
+	}
+	for _, x := range vecs[4] {
+		if x != 0 {
+			t.Fatal("empty text has a non-zero embedding")
+		}
+	}
+
+	// Deterministic across calls
+	again, _ := f.GetEmbeddings(texts[:1])
+	if squaredL2(again[0], vecs[0]) != 0 {
+		t.Error("embeddings differ between calls")
+	}
+}
+
+func TestFakeProviderSimilarity(t *testing.T) {
+	f := NewFakeProvider(256)
+	vecs, _ := f.GetEmbeddings([]string{"how to stake ethereum", "staking ethereum for rewards", "cooking pasta at home"})
+	if squaredL2(vecs[0], vecs[1]) >= squaredL2(vecs[0], vecs[2]) {
+		t.Error("texts sharing words are not closer than unrelated texts")
+	}
+}
+
+func TestFakeTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Hello, World!", []string{"hello", "world"}},
+		{"ETH2.0 rocks", []string{"eth2", "0", "rocks"}},
+		{"买BTC", []string{"买", "btc"}},
+	}
+	for _, tt := range tests {
+		if got := fakeTokens(tt.text); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("fakeTokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFakeProviderChat(t *testing.T) {
+	f := NewFakeProvider(8)
+	if _, err := f.Chat(nil); err == nil {
+		t.Error("Chat with no messages succeeded")
+	}
+
+	messages := []Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "What is BTC?"}}
+	answer, err := f.Chat(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(answer, "2 message(s)") || !strings.Contains(answer, "What is BTC?") {
+		t.Errorf("Chat() = %q", answer)
+	}
+
+	// The stream adds up to the same answer
+	var streamed strings.Builder
+	got, err := f.ChatStream(context.Background(), messages, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil || got != answer || streamed.String() != answer {
+		t.Errorf("ChatStream() = %q, %v, streamed %q, want %q", got, err, streamed.String(), answer)
+	}
+
+	// Errors from the callback and cancellation stop the stream
+	stop := errors.New("client gone")
+	if _, err := f.ChatStream(context.Background(), messages, func(string) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("ChatStream() error = %v, want the callback's error", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.ChatStream(ctx, messages, func(string) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("ChatStream() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,46 @@
+package rag
+
+import (
+	"sort"
+)
+
+// fuseRRF merges ranked result lists with weighted reciprocal rank fusion:
+// a chunk scores sum(weight / (RagRRFK + rank)) over the lists it appears in,
+// so only ranks matter and BM25 scores never need to be compared with vector
+// distances. The fused score replaces SearchResult.Score.
+func fuseRRF(lists [][]SearchResult, weights []float64, topK int) []SearchResult {
+	type chunkKey struct {
+		articleID  int64
+		chunkIndex int64
+	}
+	scores := make(map[chunkKey]float64)
+	first := make(map[chunkKey]SearchResult)
+	var order []chunkKey
+
+	for l, list := range lists {
+		if weights[l] <= 0 {
+			continue
+		}
+		for rank, res := range list {
+			key := chunkKey{res.ArticleID, res.ChunkIndex}
+			if _, ok := first[key]; !ok {
+				first[key] = res
+				order = append(order, key)
+			}
+			scores[key] += weights[l] / (RagRRFK + float64(rank+1))
+		}
+	}
+
+	fused := make([]SearchResult, 0, len(order))
+	for _, key := range order {
+		res := first[key]
+		res.Score = float32(scores[key])
+		fused = append(fused, res)
+	}
+	// Stable keeps the earlier list's order on ties
+	sort.SliceStable(fused, func(i, j int) bool { return fused[i].Score > fused[j].Score })
+	if len(fused) > topK {
+		fused = fused[:topK]
+	}
+	return fused
+}
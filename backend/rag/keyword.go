@@ -0,0 +1,188 @@
+package rag
+
+import (
+	"sync"
+
+	"coin-wave/models"
+	"coin-wave/search"
+
+	"gorm.io/gorm"
+)
+
+// KeywordRetriever is a BM25 index over models.Chunk. It catches exact terms
+// such as ticker symbols ("ETH", "SOL") that dense vectors tend to miss.
+// The index lives in memory, so every replica holds its own copy: articles
+// indexed elsewhere must be passed to RagService.ReloadArticle, or keyword
+// results differ between replicas until the next restart.
+type KeywordRetriever struct {
+	db    *gorm.DB
+	index *search.Index
+
+	mu        sync.RWMutex
+	chunks    map[uint]keywordChunk // Chunk ID -> owner and position
+	byArticle map[uint][]uint       // Article ID -> chunk IDs
+}
+
+type keywordChunk struct {
+	ArticleID  uint
+	AuthorID   uint
+	ChunkIndex int
+	VectorID   int64
+}
+
+// keywordRow is a chunk joined with the article fields it is indexed with.
+type keywordRow struct {
+	ID         uint
+	ArticleID  uint
+	ChunkIndex int
+	VectorID   int64
+	Content    string
+	Breadcrumb string
+	Title      string
+	Tags       string
+	AuthorID   uint
+}
+
+func NewKeywordRetriever(db *gorm.DB) *KeywordRetriever {
+	return &KeywordRetriever{
+		db: db,
+		index: search.NewIndex(
+			search.Field{Name: "title", Weight: 2},
+			search.Field{Name: "tags", Weight: 2},
+			search.Field{Name: "breadcrumb", Weight: 1.5},
+			search.Field{Name: "content", Weight: 1},
+		),
+		chunks:    make(map[uint]keywordChunk),
+		byArticle: make(map[uint][]uint),
+	}
+}
+
+func (k *KeywordRetriever) rows() *gorm.DB {
+	return k.db.Table("chunks").
+		Select("chunks.id, chunks.article_id, chunks.chunk_index, chunks.vector_id, chunks.content, chunks.breadcrumb, articles.title, articles.tags, articles.author_id").
+		Joins("JOIN articles ON articles.id = chunks.article_id AND articles.deleted_at IS NULL").
+		Where("chunks.deleted_at IS NULL")
+}
+
+// Load indexes every stored chunk. It is called once at startup.
+func (k *KeywordRetriever) Load() error {
+	var rows []keywordRow
+	if err := k.rows().Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		k.add(row)
+	}
+	return nil
+}
+
+// IndexArticle replaces the indexed chunks of an article with its current rows.
+func (k *KeywordRetriever) IndexArticle(articleID uint) error {
+	var rows []keywordRow
+	if err := k.rows().Where("chunks.article_id = ?", articleID).Find(&rows).Error; err != nil {
+		return err
+	}
+	k.RemoveArticle(articleID)
+	for _, row := range rows {
+		k.add(row)
+	}
+	return nil
+}
+
+// RemoveArticle drops every chunk of an article from the index.
+func (k *KeywordRetriever) RemoveArticle(articleID uint) {
+	k.mu.Lock()
+	ids := k.byArticle[articleID]
+	delete(k.byArticle, articleID)
+	for _, id := range ids {
+		delete(k.chunks, id)
+	}
+	k.mu.Unlock()
+
+	for _, id := range ids {
+		k.index.Remove(id)
+	}
+}
+
+func (k *KeywordRetriever) add(row keywordRow) {
+	k.mu.Lock()
+	k.chunks[row.ID] = keywordChunk{
+		ArticleID:  row.ArticleID,
+		AuthorID:   row.AuthorID,
+		ChunkIndex: row.ChunkIndex,
+		VectorID:   row.VectorID,
+	}
+	k.byArticle[row.ArticleID] = append(k.byArticle[row.ArticleID], row.ID)
+	k.mu.Unlock()
+
+	k.index.Add(row.ID, row.Title, row.Tags, row.Breadcrumb, row.Content)
+}
+
+// Search returns the best matching chunks within the filter, best first.
+// Scores are BM25 scores and not comparable with vector distances.
+func (k *KeywordRetriever) Search(filter SearchFilter, query string, topK int) ([]SearchResult, error) {
+	hits, refs := k.match(filter, query, topK)
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	// Contents stay in MySQL rather than in memory
+	ids := make([]uint, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	var chunks []models.Chunk
+	if err := k.db.Select("id, content").Where("id IN ?", ids).Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	contents := make(map[uint]string, len(chunks))
+	for _, c := range chunks {
+		contents[c.ID] = c.Content
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for i, h := range hits {
+		content, ok := contents[h.ID]
+		if !ok {
+			continue // Deleted since the index was updated
+		}
+		results = append(results, SearchResult{
+			ID:         refs[i].VectorID,
+			Score:      float32(h.Score),
+			Content:    content,
+			ArticleID:  int64(refs[i].ArticleID),
+			ChunkIndex: int64(refs[i].ChunkIndex),
+		})
+	}
+	return results, nil
+}
+
+// match ranks the indexed chunks within the filter, returning the hits and
+// the chunk each one refers to.
+func (k *KeywordRetriever) match(filter SearchFilter, query string, topK int) ([]search.Hit, []keywordChunk) {
+	var allowed map[uint]bool
+	if filter.ArticleIDs != nil {
+		allowed = make(map[uint]bool, len(filter.ArticleIDs))
+		for _, id := range filter.ArticleIDs {
+			allowed[id] = true
+		}
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	hits := k.index.Search(query, topK, func(id uint) bool {
+		c, ok := k.chunks[id]
+		if !ok {
+			return false
+		}
+		if filter.UserID != 0 && c.AuthorID != filter.UserID {
+			return false
+		}
+		return allowed == nil || allowed[c.ArticleID]
+	})
+	refs := make([]keywordChunk, len(hits))
+	for i, h := range hits {
+		refs[i] = k.chunks[h.ID]
+	}
+	return hits, refs
+}
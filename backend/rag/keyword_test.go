@@ -0,0 +1,129 @@
+package rag
+
+import (
+	"testing"
+)
+
+func newTestKeywordRetriever(rows ...keywordRow) *KeywordRetriever {
+	k := NewKeywordRetriever(nil)
+	for _, row := range rows {
+		k.add(row)
+	}
+	return k
+}
+
+var keywordRows = []keywordRow{
+	{ID: 1, ArticleID: 10, ChunkIndex: 0, VectorID: 100, AuthorID: 1, Title: "Ethereum staking guide", Tags: "eth,staking", Content: "Stake ETH with a validator to earn rewards."},
+	{ID: 2, ArticleID: 10, ChunkIndex: 1, VectorID: 101, AuthorID: 1, Title: "Ethereum staking guide", Tags: "eth,staking", Content: "Withdrawals unlock after the Shanghai upgrade."},
+	{ID: 3, ArticleID: 20, ChunkIndex: 0, VectorID: 200, AuthorID: 2, Title: "Solana outlook", Tags: "sol", Content: "SOL fees stay low while ETH gas spikes."},
+	{ID: 4, ArticleID: 30, ChunkIndex: 0, VectorID: 300, AuthorID: 2, Title: "Bitcoin halving", Breadcrumb: "Supply", Content: "The block reward halves every four years."},
+}
+
+func TestKeywordRetrieverMatch(t *testing.T) {
+	k := newTestKeywordRetriever(keywordRows...)
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		query  string
+		topK   int
+		want   []int64 // Vector IDs, best first
+	}{
+		{"ticker symbol, tags outweigh content", SearchFilter{}, "ETH", 10, []int64{100, 101, 200}},
+		{"case insensitive", SearchFilter{}, "eth", 1, []int64{100}},
+		{"breadcrumb is indexed", SearchFilter{}, "supply", 10, []int64{300}},
+		{"author filter", SearchFilter{UserID: 2}, "eth", 10, []int64{200}},
+		{"article filter", SearchFilter{ArticleIDs: []uint{20, 30}}, "eth", 10, []int64{200}},
+		{"empty article filter matches nothing", SearchFilter{ArticleIDs: []uint{}}, "eth", 10, nil},
+		{"no match", SearchFilter{}, "dogecoin", 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, refs := k.match(tt.filter, tt.query, tt.topK)
+			if len(hits) != len(refs) {
+				t.Fatalf("%d hits but %d refs", len(hits), len(refs))
+			}
+			var got []int64
+			for _, r := range refs {
+				got = append(got, r.VectorID)
+			}
+			if !equalInt64s(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for i := 1; i < len(hits); i++ {
+				if hits[i].Score > hits[i-1].Score {
+					t.Errorf("hits not sorted by score: %+v", hits)
+				}
+			}
+		})
+	}
+}
+
+func TestKeywordRetrieverRemoveArticle(t *testing.T) {
+	k := newTestKeywordRetriever(keywordRows...)
+	k.RemoveArticle(10)
+
+	hits, refs := k.match(SearchFilter{}, "eth", 10)
+	if len(hits) != 1 || refs[0].ArticleID != 20 {
+		t.Errorf("after removing article 10 got %+v, want article 20 only", refs)
+	}
+	if _, ok := k.byArticle[10]; ok {
+		t.Error("article 10 still tracked")
+	}
+	// Removing an unknown article is a no-op
+	k.RemoveArticle(99)
+}
+
+func TestFuseRRF(t *testing.T) {
+	r := func(article, chunk int64) SearchResult {
+		return SearchResult{ArticleID: article, ChunkIndex: chunk, Content: "x"}
+	}
+	vector := []SearchResult{r(1, 0), r(2, 0), r(3, 0)}
+	keyword := []SearchResult{r(3, 0), r(2, 0), r(4, 0)}
+
+	tests := []struct {
+		name    string
+		lists   [][]SearchResult
+		weights []float64
+		topK    int
+		want    []int64 // Article IDs, best first
+	}{
+		{"found by both lists wins", [][]SearchResult{vector, keyword}, []float64{1, 1}, 10, []int64{3, 2, 1, 4}},
+		{"zero weight ignores a list", [][]SearchResult{vector, keyword}, []float64{1, 0}, 10, []int64{1, 2, 3}},
+		{"heavier list dominates", [][]SearchResult{vector, keyword}, []float64{1, 5}, 10, []int64{3, 2, 4, 1}},
+		{"ties keep the first list's order", [][]SearchResult{{r(1, 0)}, {r(2, 0)}}, []float64{1, 1}, 10, []int64{1, 2}},
+		{"top k", [][]SearchResult{vector, keyword}, []float64{1, 1}, 2, []int64{3, 2}},
+		{"empty", [][]SearchResult{nil, nil}, []float64{1, 1}, 5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fused := fuseRRF(tt.lists, tt.weights, tt.topK)
+			var got []int64
+			for _, f := range fused {
+				got = append(got, f.ArticleID)
+			}
+			if !equalInt64s(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The fused score is the weighted sum of reciprocal ranks
+	fused := fuseRRF([][]SearchResult{vector, keyword}, []float64{1, 1}, 1)
+	want := 1/(RagRRFK+3) + 1/(RagRRFK+1)
+	if diff := float64(fused[0].Score) - want; diff > 1e-6 || diff < -1e-6 {
+		t.Errorf("score = %v, want %v", fused[0].Score, want)
+	}
+}
+
+func equalInt64s(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package rag
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// LocalStore is a pure-Go brute-force vector store persisted to a single file.
+// It is meant for small deployments and integration tests without Milvus.
+type LocalStore struct {
+	mu      sync.RWMutex
+	path    string
+	nextID  int64
+	records []localRecord
+}
+
+type localRecord struct {
+	ID         int64
+	UserID     uint
+	ArticleID  uint
+	ChunkIndex int
+	Content    string
+	Embedding  []float32
+}
+
+// localSnapshot is the on-disk format.
+type localSnapshot struct {
+	NextID  int64
+	Records []localRecord
+}
+
+func NewLocalStore(path string) *LocalStore {
+	return &LocalStore{path: path, nextID: 1}
+}
+
+// InitCollection loads the snapshot from disk if one exists.
+func (l *LocalStore) InitCollection(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snap localSnapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return err
+	}
+	l.records = snap.Records
+	l.nextID = snap.NextID
+	if l.nextID < 1 {
+		l.nextID = 1
+	}
+	return nil
+}
+
+func (l *LocalStore) InsertChunks(ctx context.Context, chunks []ChunkData) ([]int64, error) {
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids := make([]int64, len(chunks))
+	for i, c := range chunks {
+		ids[i] = l.nextID
+		l.records = append(l.records, localRecord{
+			ID:         l.nextID,
+			UserID:     c.UserID,
+			ArticleID:  c.ArticleID,
+			ChunkIndex: c.ChunkIndex,
+			Content:    c.Content,
+			Embedding:  c.Embedding,
+		})
+		l.nextID++
+	}
+
+	if err := l.persist(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (l *LocalStore) Search(ctx context.Context, filter SearchFilter, queryVector []float32, topK int) ([]SearchResult, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var allowed map[uint]bool
+	if filter.ArticleIDs != nil {
+		allowed = make(map[uint]bool, len(filter.ArticleIDs))
+		for _, id := range filter.ArticleIDs {
+			allowed[id] = true
+		}
+	}
+
+	var ret []SearchResult
+	for _, r := range l.records {
+		if filter.UserID != 0 && r.UserID != filter.UserID {
+			continue
+		}
+		if allowed != nil && !allowed[r.ArticleID] {
+			continue
+		}
+		if len(r.Embedding) != len(queryVector) {
+			continue
+		}
+		ret = append(ret, SearchResult{
+			ID:         r.ID,
+			Score:      squaredL2(r.Embedding, queryVector),
+			Content:    r.Content,
+			ArticleID:  int64(r.ArticleID),
+			ChunkIndex: int64(r.ChunkIndex),
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Score < ret[j].Score
+	})
+	if topK > 0 && len(ret) > topK {
+		ret = ret[:topK]
+	}
+	return ret, nil
+}
+
+func (l *LocalStore) DeleteByArticle(ctx context.Context, articleID uint) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := l.records[:0]
+	for _, r := range l.records {
+		if r.ArticleID != articleID {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(l.records) {
+		return nil
+	}
+	l.records = kept
+	return l.persist()
+}
+
+// persist writes the snapshot to a temp file and renames it into place. Caller holds the lock.
+func (l *LocalStore) persist() error {
+	if l.path == "" {
+		return nil
+	}
+
+	tmp := l.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(localSnapshot{NextID: l.nextID, Records: l.records}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// squaredL2 matches the distance reported by Milvus for the L2 metric.
+func squaredL2(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
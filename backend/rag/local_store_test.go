@@ -0,0 +1,120 @@
+package rag
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// newTestLocalStore returns a store persisted under a temp dir, holding
+// chunks at known distances from the origin.
+func newTestLocalStore(t *testing.T) (*LocalStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vectors", "local.gob")
+	l := NewLocalStore(path)
+	if err := l.InitCollection(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := l.InsertChunks(context.Background(), []ChunkData{
+		{UserID: 1, ArticleID: 10, ChunkIndex: 0, Content: "a", Embedding: []float32{0, 0}},
+		{UserID: 1, ArticleID: 10, ChunkIndex: 1, Content: "b", Embedding: []float32{1, 0}},
+		{UserID: 2, ArticleID: 20, ChunkIndex: 0, Content: "c", Embedding: []float32{3, 0}},
+		{UserID: 1, ArticleID: 30, ChunkIndex: 0, Content: "d", Embedding: []float32{0, 2}},
+		{UserID: 1, ArticleID: 30, ChunkIndex: 1, Content: "e", Embedding: []float32{0, 0, 0}}, // Other dimension
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalInt64s(ids, []int64{1, 2, 3, 4, 5}) {
+		t.Fatalf("InsertChunks() ids = %v, want 1..5", ids)
+	}
+	return l, path
+}
+
+func resultIDs(results []SearchResult) []int64 {
+	ids := make([]int64, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestLocalStoreSearch(t *testing.T) {
+	l, _ := newTestLocalStore(t)
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		topK   int
+		want   []int64
+	}{
+		{"nearest first, other dimensions skipped", SearchFilter{}, 0, []int64{1, 2, 4, 3}},
+		{"topK", SearchFilter{}, 2, []int64{1, 2}},
+		{"user", SearchFilter{UserID: 2}, 0, []int64{3}},
+		{"articles", SearchFilter{ArticleIDs: []uint{20, 30}}, 0, []int64{4, 3}},
+		{"user and articles", SearchFilter{UserID: 1, ArticleIDs: []uint{20, 30}}, 0, []int64{4}},
+		{"empty article list", SearchFilter{ArticleIDs: []uint{}}, 0, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := l.Search(context.Background(), tt.filter, []float32{0, 0}, tt.topK)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resultIDs(results); !equalInt64s(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	results, _ := l.Search(context.Background(), SearchFilter{ArticleIDs: []uint{30}}, []float32{0, 0}, 0)
+	if r := results[0]; r.Score != 4 || r.Content != "d" || r.ArticleID != 30 || r.ChunkIndex != 0 {
+		t.Errorf("Search() result = %+v", r)
+	}
+}
+
+func TestLocalStoreDeleteAndReload(t *testing.T) {
+	l, path := newTestLocalStore(t)
+	ctx := context.Background()
+
+	if err := l.DeleteByArticle(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DeleteByArticle(ctx, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewLocalStore(path)
+	if err := reloaded.InitCollection(ctx); err != nil {
+		t.Fatal(err)
+	}
+	results, _ := reloaded.Search(ctx, SearchFilter{}, []float32{0, 0}, 0)
+	if got := resultIDs(results); !equalInt64s(got, []int64{4, 3}) {
+		t.Errorf("Search() after reload = %v, want [4 3]", got)
+	}
+
+	// Ids keep counting from where the previous store stopped
+	ids, err := reloaded.InsertChunks(ctx, []ChunkData{{UserID: 1, ArticleID: 40, Embedding: []float32{1, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalInt64s(ids, []int64{6}) {
+		t.Errorf("InsertChunks() after reload = %v, want [6]", ids)
+	}
+}
+
+func TestLocalStoreWithoutPath(t *testing.T) {
+	l := NewLocalStore("")
+	ctx := context.Background()
+	if err := l.InitCollection(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.InsertChunks(ctx, []ChunkData{{ArticleID: 1, Embedding: []float32{1}}}); err != nil {
+		t.Fatal(err)
+	}
+	results, _ := l.Search(ctx, SearchFilter{}, []float32{1}, 0)
+	if len(results) != 1 {
+		t.Errorf("Search() = %v, want the in-memory chunk", results)
+	}
+}
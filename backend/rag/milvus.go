@@ -3,6 +3,8 @@ package rag
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
@@ -155,17 +157,20 @@ func (m *MilvusStore) InsertChunks(ctx context.Context, chunks []ChunkData) ([]i
 	return nil, nil
 }
 
-type SearchResult struct {
-	ID         int64
-	Score      float32
-	Content    string
-	ArticleID  int64
-	ChunkIndex int64
-}
-
-func (m *MilvusStore) Search(ctx context.Context, userID uint, queryVector []float32, topK int) ([]SearchResult, error) {
-	// Filter by user_id
-	expr := fmt.Sprintf("user_id == %d", userID)
+func (m *MilvusStore) Search(ctx context.Context, filter SearchFilter, queryVector []float32, topK int) ([]SearchResult, error) {
+	// Filter by user_id / article_id
+	var conds []string
+	if filter.UserID != 0 {
+		conds = append(conds, fmt.Sprintf("user_id == %d", filter.UserID))
+	}
+	if filter.ArticleIDs != nil {
+		ids := make([]string, len(filter.ArticleIDs))
+		for i, id := range filter.ArticleIDs {
+			ids[i] = strconv.FormatUint(uint64(id), 10)
+		}
+		conds = append(conds, fmt.Sprintf("article_id in [%s]", strings.Join(ids, ",")))
+	}
+	expr := strings.Join(conds, " && ")
 
 	sp, _ := entity.NewIndexIvfFlatSearchParam(10) // nprobe
 
@@ -225,10 +230,7 @@ func (m *MilvusStore) Search(ctx context.Context, userID uint, queryVector []flo
 	return ret, nil
 }
 
-type ChunkData struct {
-	UserID     uint
-	ArticleID  uint
-	ChunkIndex int
-	Content    string
-	Embedding  []float32
+func (m *MilvusStore) DeleteByArticle(ctx context.Context, articleID uint) error {
+	expr := fmt.Sprintf("article_id == %d", articleID)
+	return m.cli.Delete(ctx, MilvusCollectionName, "", expr)
 }
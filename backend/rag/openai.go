@@ -0,0 +1,168 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// OpenAIClient talks to any OpenAI-compatible HTTP API (OpenAI, Ollama, vLLM, ...).
+type OpenAIClient struct {
+	client *resty.Client
+	stream *resty.Client // For ChatStream, without the overall timeout
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+type openAIChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream,omitempty"`
+}
+
+type openAIChatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+func NewOpenAIClient() *OpenAIClient {
+	c := resty.New().
+		SetBaseURL(strings.TrimRight(OpenAIBaseURL, "/")).
+		SetHeader("Content-Type", "application/json").
+		SetTimeout(120 * time.Second)
+	stream := newStreamClient().
+		SetBaseURL(strings.TrimRight(OpenAIBaseURL, "/")).
+		SetHeader("Content-Type", "application/json")
+	// Local servers such as Ollama do not need a key
+	if OpenAIAPIKey != "" {
+		c.SetHeader("Authorization", "Bearer "+OpenAIAPIKey)
+		stream.SetHeader("Authorization", "Bearer "+OpenAIAPIKey)
+	}
+	return &OpenAIClient{client: c, stream: stream}
+}
+
+func (c *OpenAIClient) GetEmbeddings(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var respBody openAIEmbeddingResponse
+	resp, err := c.client.R().
+		SetBody(openAIEmbeddingRequest{Model: OpenAIModelEmbedding, Input: texts}).
+		SetResult(&respBody).
+		Post("/embeddings")
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("api error: %s", resp.String())
+	}
+	if respBody.Error != nil {
+		return nil, fmt.Errorf("api error: %s", respBody.Error.Message)
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, item := range respBody.Data {
+		if item.Index < 0 || item.Index >= len(embeddings) {
+			return nil, fmt.Errorf("api returned embedding index %d for %d inputs", item.Index, len(texts))
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+	return embeddings, nil
+}
+
+func (c *OpenAIClient) Chat(messages []Message) (string, error) {
+	var respBody openAIChatResponse
+	resp, err := c.client.R().
+		SetBody(openAIChatRequest{Model: OpenAIModelLLM, Messages: messages}).
+		SetResult(&respBody).
+		Post("/chat/completions")
+	if err != nil {
+		return "", err
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("api error: %s", resp.String())
+	}
+	if respBody.Error != nil {
+		return "", fmt.Errorf("api error: %s", respBody.Error.Message)
+	}
+
+	if len(respBody.Choices) == 0 {
+		return "", errors.New("no choices found in response")
+	}
+	return respBody.Choices[0].Message.Content, nil
+}
+
+// ChatStream requests a streamed completion and forwards content deltas to onDelta.
+// Cancelling ctx aborts the upstream request.
+func (c *OpenAIClient) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	resp, err := c.stream.R().
+		SetContext(ctx).
+		SetBody(openAIChatRequest{Model: OpenAIModelLLM, Messages: messages, Stream: true}).
+		SetHeader("Accept", "text/event-stream").
+		SetDoNotParseResponse(true).
+		Post("/chat/completions")
+	if err != nil {
+		return "", err
+	}
+	body := withIdleTimeout(resp.RawBody(), StreamIdleTimeout)
+	defer body.Close()
+
+	if resp.IsError() {
+		raw, _ := io.ReadAll(body)
+		return "", fmt.Errorf("api error: %s", string(raw))
+	}
+
+	var answer strings.Builder
+	err = readSSE(body, func(event, data string) error {
+		if data == "[DONE]" {
+			return errStreamDone
+		}
+		var chunk openAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil // Skip keep-alives and unknown payloads
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("api error: %s", chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			answer.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return answer.String(), err
+}
@@ -0,0 +1,103 @@
+package rag
+
+import (
+	"sync"
+	"time"
+
+	"coin-wave/models"
+)
+
+// Ingestion stages reported in ProgressEvent.Stage
+const (
+	StageQueued = "queued"
+	StageFetch  = "fetch"
+	StageChunk  = "chunk"
+	StageEmbed  = "embed"
+	StageStore  = "store"
+	StageDone   = "done"
+)
+
+// ProgressEvent is a snapshot of an article's ingestion state.
+type ProgressEvent struct {
+	ArticleID uint      `json:"article_id"`
+	Status    string    `json:"status"` // pending, processing, completed, failed
+	Stage     string    `json:"stage"`
+	Progress  int       `json:"progress"` // 0-100
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Terminal reports whether no further events will follow without a new ingestion.
+func (e ProgressEvent) Terminal() bool {
+	return e.Status == "completed" || e.Status == "failed"
+}
+
+// ProgressHub fans ingestion progress out to in-process subscribers.
+type ProgressHub struct {
+	mu   sync.Mutex
+	subs map[uint]map[chan ProgressEvent]struct{}
+}
+
+func NewProgressHub() *ProgressHub {
+	return &ProgressHub{subs: make(map[uint]map[chan ProgressEvent]struct{})}
+}
+
+// Subscribe returns a channel of events for one article and a function to unsubscribe.
+func (h *ProgressHub) Subscribe(articleID uint) (<-chan ProgressEvent, func()) {
+	ch := make(chan ProgressEvent, 16)
+
+	h.mu.Lock()
+	if h.subs[articleID] == nil {
+		h.subs[articleID] = make(map[chan ProgressEvent]struct{})
+	}
+	h.subs[articleID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[articleID], ch)
+			if len(h.subs[articleID]) == 0 {
+				delete(h.subs, articleID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Publish delivers an event to every subscriber of the article.
+// Slow subscribers miss events rather than blocking ingestion.
+func (h *ProgressHub) Publish(ev ProgressEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[ev.ArticleID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// reportProgress persists the ingestion state on the article and notifies subscribers.
+func (s *RagService) reportProgress(articleID uint, status, stage string, progress int, errMsg string) {
+	s.db.Model(&models.Article{}).Where("id = ?", articleID).Updates(map[string]interface{}{
+		"vector_status":   status,
+		"vector_stage":    stage,
+		"vector_progress": progress,
+		"vector_error":    errMsg,
+	})
+	s.progress.Publish(ProgressEvent{
+		ArticleID: articleID,
+		Status:    status,
+		Stage:     stage,
+		Progress:  progress,
+		Error:     errMsg,
+		At:        time.Now(),
+	})
+}
+
+// SubscribeProgress streams ingestion events for an article.
+func (s *RagService) SubscribeProgress(articleID uint) (<-chan ProgressEvent, func()) {
+	return s.progress.Subscribe(articleID)
+}
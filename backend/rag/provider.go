@@ -0,0 +1,38 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Embedder turns texts into vectors. The returned slice is aligned with texts.
+type Embedder interface {
+	GetEmbeddings(texts []string) ([][]float32, error)
+}
+
+// ChatModel produces a completion for a list of messages. ChatStream forwards
+// text deltas to onDelta as they arrive and returns the full answer; cancelling
+// ctx aborts the upstream call.
+type ChatModel interface {
+	Chat(messages []Message) (string, error)
+	ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error)
+}
+
+// NewProviders builds the embedder and chat model selected by RagProvider.
+func NewProviders() (Embedder, ChatModel, error) {
+	switch RagProvider {
+	case "", "volc":
+		c := NewVolcClient()
+		return c, c, nil
+	case "openai":
+		c := NewOpenAIClient()
+		return c, c, nil
+	case "fake":
+		log.Println("[RAG] Using fake in-process provider, answers are not generated by a real model")
+		f := NewFakeProvider(MilvusDim)
+		return f, f, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown RAG provider: %s", RagProvider)
+	}
+}
@@ -0,0 +1,296 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"coin-wave/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ingestion job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead" // Gave up after IngestMaxAttempts, needs a manual re-index
+)
+
+// JobQueue is a MySQL-backed ingestion queue consumed by a bounded worker pool.
+// Jobs survive restarts and failed attempts are retried with exponential backoff.
+type JobQueue struct {
+	db     *gorm.DB
+	worker *IngestionWorker
+	wake   chan struct{}
+}
+
+func NewJobQueue(db *gorm.DB, worker *IngestionWorker) *JobQueue {
+	return &JobQueue{
+		db:     db,
+		worker: worker,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Enqueue schedules an article for (re-)ingestion. An article has at most one
+// queued job, so enqueueing twice only resets the existing one. A running job
+// does not stop a new one from being queued, the content may have changed
+// since it started; claim never runs both at once.
+func (q *JobQueue) Enqueue(articleID uint) error {
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize enqueues of the same article, across replicas too
+		var article models.Article
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id = ?", articleID).Limit(1).Find(&article).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		res := tx.Model(&models.IngestionJob{}).
+			Where("article_id = ? AND status = ?", articleID, JobQueued).
+			Updates(map[string]interface{}{"attempts": 0, "next_run_at": now, "last_error": ""})
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		job := models.IngestionJob{
+			ArticleID:   articleID,
+			Status:      JobQueued,
+			MaxAttempts: IngestMaxAttempts,
+			NextRunAt:   now,
+		}
+		return tx.Create(&job).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	q.worker.service.reportProgress(articleID, "pending", StageQueued, 0, "")
+
+	// Wake an idle worker instead of waiting for the next poll
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// LatestJob returns the most recent job of an article, or nil if it never had one.
+func (q *JobQueue) LatestJob(articleID uint) (*models.IngestionJob, error) {
+	var job models.IngestionJob
+	err := q.db.Where("article_id = ?", articleID).Order("id desc").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Start recovers interrupted work and launches IngestConcurrency workers.
+// Workers stop when ctx is cancelled.
+func (q *JobQueue) Start(ctx context.Context) {
+	if err := q.recover(); err != nil {
+		log.Printf("[Queue] Recovery failed: %v", err)
+	}
+	for i := 0; i < IngestConcurrency; i++ {
+		go q.loop(ctx, i)
+	}
+	// Replicas can crash mid-job while the others keep running
+	go func() {
+		ticker := time.NewTicker(IngestLeaseTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := q.requeueExpired(); err != nil {
+					log.Printf("[Queue] Requeue of expired jobs failed: %v", err)
+				}
+			}
+		}
+	}()
+	log.Printf("[Queue] Started %d ingestion workers", IngestConcurrency)
+}
+
+// recover requeues jobs abandoned while running, and enqueues articles left
+// pending or processing without any job (e.g. by the old fire-and-forget
+// worker). Jobs other replicas are running are left alone.
+func (q *JobQueue) recover() error {
+	if err := q.requeueExpired(); err != nil {
+		return err
+	}
+
+	active := q.db.Model(&models.IngestionJob{}).Select("article_id").Where("status IN ?", []string{JobQueued, JobRunning})
+	var orphans []uint
+	if err := q.db.Model(&models.Article{}).
+		Where("vector_status IN ?", []string{"pending", "processing"}).
+		Where("id NOT IN (?)", active).
+		Pluck("id", &orphans).Error; err != nil {
+		return err
+	}
+	for _, id := range orphans {
+		if err := q.Enqueue(id); err != nil {
+			return err
+		}
+	}
+	if len(orphans) > 0 {
+		log.Printf("[Queue] Enqueued %d articles stuck in pending/processing", len(orphans))
+	}
+	return nil
+}
+
+// requeueExpired requeues running jobs whose lease ran out, their worker is
+// gone.
+func (q *JobQueue) requeueExpired() error {
+	res := q.db.Model(&models.IngestionJob{}).
+		Where("status = ? AND (locked_at IS NULL OR locked_at < ?)", JobRunning, time.Now().Add(-IngestLeaseTimeout)).
+		Updates(map[string]interface{}{"status": JobQueued, "locked_at": nil, "next_run_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("[Queue] Requeued %d interrupted jobs", res.RowsAffected)
+	}
+	return nil
+}
+
+func (q *JobQueue) loop(ctx context.Context, id int) {
+	ticker := time.NewTicker(IngestPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain everything that is due before sleeping again
+		for {
+			job, err := q.claim()
+			if err != nil {
+				log.Printf("[Queue] Worker %d failed to claim job: %v", id, err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			q.process(ctx, job)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// claim atomically moves the next due job from queued to running.
+// It returns nil when nothing is due.
+func (q *JobQueue) claim() (*models.IngestionJob, error) {
+	for {
+		// Never run two jobs for the same article at once
+		running := q.db.Model(&models.IngestionJob{}).Select("article_id").Where("status = ?", JobRunning)
+
+		var job models.IngestionJob
+		err := q.db.Where("status = ? AND next_run_at <= ?", JobQueued, time.Now()).
+			Where("article_id NOT IN (?)", running).
+			Order("next_run_at asc").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Another worker may have grabbed it between the select and the update
+		now := time.Now()
+		res := q.db.Model(&models.IngestionJob{}).
+			Where("id = ? AND status = ?", job.ID, JobQueued).
+			Updates(map[string]interface{}{"status": JobRunning, "locked_at": now})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			job.Status = JobRunning
+			job.LockedAt = &now
+			return &job, nil
+		}
+	}
+}
+
+func (q *JobQueue) process(ctx context.Context, job *models.IngestionJob) {
+	var count int64
+	q.db.Model(&models.Article{}).Where("id = ?", job.ArticleID).Count(&count)
+	if count == 0 {
+		// Deleted articles will never succeed, don't retry
+		q.finish(job, JobDead, "article not found")
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, IngestJobTimeout)
+	err := q.worker.Invoke(jobCtx, job.ArticleID)
+	cancel()
+
+	if err == nil {
+		log.Printf("[Queue] Ingestion completed for article %d", job.ArticleID)
+		q.finish(job, JobSucceeded, "")
+		return
+	}
+
+	job.Attempts++
+	if job.Attempts >= job.MaxAttempts {
+		log.Printf("[Queue] Ingestion for article %d failed permanently after %d attempts: %v", job.ArticleID, job.Attempts, err)
+		q.finish(job, JobDead, err.Error())
+		q.worker.service.reportProgress(job.ArticleID, "failed", q.failedStage(job.ArticleID), 0, err.Error())
+		return
+	}
+
+	delay := retryDelay(job.Attempts)
+	log.Printf("[Queue] Ingestion for article %d failed (attempt %d/%d), retrying in %s: %v", job.ArticleID, job.Attempts, job.MaxAttempts, delay, err)
+	q.db.Model(job).Updates(map[string]interface{}{
+		"status":      JobQueued,
+		"attempts":    job.Attempts,
+		"next_run_at": time.Now().Add(delay),
+		"locked_at":   nil,
+		"last_error":  err.Error(),
+	})
+	// Keep the failure reason visible while waiting for the retry
+	q.worker.service.reportProgress(job.ArticleID, "pending", StageQueued, 0, err.Error())
+}
+
+// failedStage returns the last stage the article reached, i.e. where ingestion failed.
+func (q *JobQueue) failedStage(articleID uint) string {
+	var article models.Article
+	if err := q.db.Select("vector_stage").First(&article, articleID).Error; err != nil {
+		return ""
+	}
+	return article.VectorStage
+}
+
+func (q *JobQueue) finish(job *models.IngestionJob, status, lastError string) {
+	q.db.Model(job).Updates(map[string]interface{}{
+		"status":     status,
+		"attempts":   job.Attempts,
+		"locked_at":  nil,
+		"last_error": lastError,
+	})
+}
+
+// retryDelay doubles IngestRetryBase per attempt, capped at IngestRetryMax.
+func retryDelay(attempt int) time.Duration {
+	delay := IngestRetryBase
+	for i := 1; i < attempt && delay < IngestRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > IngestRetryMax {
+		delay = IngestRetryMax
+	}
+	return delay
+}
@@ -0,0 +1,130 @@
+package rag
+
+import (
+	"testing"
+	"time"
+
+	"coin-wave/database/dbtest"
+	"coin-wave/models"
+
+	"gorm.io/gorm"
+)
+
+func TestRetryDelay(t *testing.T) {
+	defer func(base, max time.Duration) { IngestRetryBase, IngestRetryMax = base, max }(IngestRetryBase, IngestRetryMax)
+	IngestRetryBase, IngestRetryMax = 10*time.Second, time.Minute
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func newTestQueue(t *testing.T) (*JobQueue, *gorm.DB) {
+	db := dbtest.Open(t, &models.IngestionJob{})
+	return NewJobQueue(db, nil), db
+}
+
+func createJob(t *testing.T, db *gorm.DB, job models.IngestionJob) models.IngestionJob {
+	t.Helper()
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = IngestMaxAttempts
+	}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func claimedArticle(t *testing.T, q *JobQueue) uint {
+	t.Helper()
+	job, err := q.claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		return 0
+	}
+	if job.Status != JobRunning || job.LockedAt == nil {
+		t.Errorf("claimed job %d is %q with lock %v, want running and locked", job.ID, job.Status, job.LockedAt)
+	}
+	return job.ArticleID
+}
+
+func TestClaim(t *testing.T) {
+	q, db := newTestQueue(t)
+	now := time.Now()
+	createJob(t, db, models.IngestionJob{ArticleID: 2, Status: JobQueued, NextRunAt: now.Add(-time.Minute)})
+	createJob(t, db, models.IngestionJob{ArticleID: 1, Status: JobQueued, NextRunAt: now.Add(-time.Hour)})
+	createJob(t, db, models.IngestionJob{ArticleID: 3, Status: JobQueued, NextRunAt: now.Add(time.Hour)}) // Not due
+	createJob(t, db, models.IngestionJob{ArticleID: 4, Status: JobSucceeded, NextRunAt: now.Add(-time.Hour)})
+
+	// Due jobs in next_run_at order, each claimed once
+	for _, want := range []uint{1, 2, 0} {
+		if got := claimedArticle(t, q); got != want {
+			t.Fatalf("claimed article %d, want %d", got, want)
+		}
+	}
+
+	// A job queued while another of the same article runs waits for it
+	createJob(t, db, models.IngestionJob{ArticleID: 1, Status: JobQueued, NextRunAt: now.Add(-time.Hour)})
+	if got := claimedArticle(t, q); got != 0 {
+		t.Fatalf("claimed article %d while its other job runs", got)
+	}
+	db.Model(&models.IngestionJob{}).Where("article_id = ? AND status = ?", 1, JobRunning).Update("status", JobSucceeded)
+	if got := claimedArticle(t, q); got != 1 {
+		t.Fatalf("claimed article %d, want 1 once its other job finished", got)
+	}
+}
+
+func TestRequeueExpired(t *testing.T) {
+	q, db := newTestQueue(t)
+	now := time.Now()
+	expired := now.Add(-2 * IngestLeaseTimeout)
+	stale := createJob(t, db, models.IngestionJob{ArticleID: 1, Status: JobRunning, NextRunAt: expired, LockedAt: &expired})
+	unlocked := createJob(t, db, models.IngestionJob{ArticleID: 2, Status: JobRunning, NextRunAt: expired})
+	live := createJob(t, db, models.IngestionJob{ArticleID: 3, Status: JobRunning, NextRunAt: now, LockedAt: &now})
+
+	if err := q.requeueExpired(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		job  models.IngestionJob
+		want string
+	}{
+		{stale, JobQueued},
+		{unlocked, JobQueued},
+		{live, JobRunning}, // Another replica is still working on it
+	} {
+		var got models.IngestionJob
+		if err := db.First(&got, tt.job.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tt.want {
+			t.Errorf("job of article %d is %q, want %q", got.ArticleID, got.Status, tt.want)
+		}
+		if tt.want == JobQueued && got.LockedAt != nil {
+			t.Errorf("requeued job of article %d is still locked", got.ArticleID)
+		}
+	}
+
+	// Requeued jobs are due again, the live one's article stays blocked
+	claimed := map[uint]bool{}
+	for article := claimedArticle(t, q); article != 0; article = claimedArticle(t, q) {
+		claimed[article] = true
+	}
+	if !claimed[1] || !claimed[2] || len(claimed) != 2 {
+		t.Errorf("claimed articles %v after requeue, want 1 and 2", claimed)
+	}
+}
@@ -0,0 +1,303 @@
+package rag
+
+import (
+	"encoding/binary"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"coin-wave/models"
+
+	"gorm.io/gorm"
+)
+
+// RelatedArticle is an article similar to another one.
+type RelatedArticle struct {
+	ArticleID  uint    `json:"article_id"`
+	Similarity float64 `json:"similarity"` // Cosine similarity of the article embeddings
+}
+
+// OnIndexChange registers fn to be called after an article's vectors are
+// replaced or deleted, e.g. to invalidate caches derived from them.
+func (s *RagService) OnIndexChange(fn func(articleID uint)) {
+	s.indexHooks = append(s.indexHooks, fn)
+}
+
+func (s *RagService) notifyIndexChange(articleID uint) {
+	for _, fn := range s.indexHooks {
+		fn(articleID)
+	}
+}
+
+// storeArticleEmbedding saves the normalised mean of the chunk vectors as the
+// article's embedding.
+func (s *RagService) storeArticleEmbedding(articleID uint, chunks []ChunkData) error {
+	mean := meanVector(chunks)
+	if mean == nil {
+		return nil
+	}
+	err := s.db.Create(&models.ArticleEmbedding{
+		ArticleID: articleID,
+		AuthorID:  chunks[0].UserID,
+		Dim:       len(mean),
+		Vector:    encodeVector(mean),
+	}).Error
+	if err != nil {
+		return err
+	}
+	s.vectors.put(articleID, articleVector{authorID: chunks[0].UserID, vec: mean})
+	return nil
+}
+
+func (s *RagService) deleteArticleEmbedding(articleID uint) error {
+	if err := s.db.Unscoped().Where("article_id = ?", articleID).Delete(&models.ArticleEmbedding{}).Error; err != nil {
+		return err
+	}
+	s.vectors.remove(articleID)
+	return nil
+}
+
+// articleVectors keeps the article embeddings in memory, so ranking articles
+// by similarity does not read every row on each request. Changes made by this
+// process are applied in place; those made by other replicas show up when it
+// is reloaded, RelatedVectorsTTL after the last load.
+type articleVectors struct {
+	mu       sync.Mutex
+	byID     map[uint]articleVector // Replaced, never modified, once handed out
+	loadedAt time.Time
+}
+
+type articleVector struct {
+	authorID uint
+	vec      []float32 // Unit length
+}
+
+// get returns the article vectors, loading them if they are missing or stale.
+// The map must not be modified.
+func (v *articleVectors) get(db *gorm.DB) (map[uint]articleVector, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.byID != nil && time.Since(v.loadedAt) < RelatedVectorsTTL {
+		return v.byID, nil
+	}
+
+	byID := make(map[uint]articleVector)
+	var batch []models.ArticleEmbedding
+	err := db.Model(&models.ArticleEmbedding{}).
+		Select("id, article_id, author_id, vector").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, e := range batch {
+				byID[e.ArticleID] = articleVector{authorID: e.AuthorID, vec: decodeVector(e.Vector)}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	v.byID, v.loadedAt = byID, time.Now()
+	return byID, nil
+}
+
+func (v *articleVectors) put(articleID uint, av articleVector) {
+	v.update(func(byID map[uint]articleVector) { byID[articleID] = av })
+}
+
+func (v *articleVectors) remove(articleID uint) {
+	v.update(func(byID map[uint]articleVector) { delete(byID, articleID) })
+}
+
+// update applies fn to a copy of the loaded vectors, as readers may still
+// hold the current map. Nothing is done before the first load.
+func (v *articleVectors) update(fn func(map[uint]articleVector)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.byID == nil {
+		return
+	}
+	byID := maps.Clone(v.byID)
+	fn(byID)
+	v.byID = byID
+}
+
+// RelatedArticles returns up to limit articles most similar to the given one,
+// by brute-force cosine similarity over the cached article embeddings. It
+// returns nil if the article has not been indexed yet.
+func (s *RagService) RelatedArticles(articleID uint, limit int, excludeAuthor bool) ([]RelatedArticle, error) {
+	vectors, err := s.vectors.get(s.db)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := vectors[articleID]
+	if !ok {
+		return nil, nil
+	}
+
+	related := []RelatedArticle{}
+	for id, av := range vectors {
+		if id == articleID || len(av.vec) != len(target.vec) || excludeAuthor && av.authorID == target.authorID {
+			continue
+		}
+		related = append(related, RelatedArticle{ArticleID: id, Similarity: dot(target.vec, av.vec)})
+	}
+
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Similarity != related[j].Similarity {
+			return related[i].Similarity > related[j].Similarity
+		}
+		return related[i].ArticleID < related[j].ArticleID
+	})
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return related, nil
+}
+
+// ProfileMatch is an article close to a user's interest profile.
+type ProfileMatch struct {
+	ArticleID  uint
+	Similarity float64 // Cosine similarity to the profile
+	SeedID     uint    // The seed article it is closest to
+}
+
+// SimilarToProfile ranks articles against an interest profile: the weighted
+// mean of the seed articles' embeddings. Seeds and excluded articles are never
+// returned. Seeds that are not indexed are ignored.
+func (s *RagService) SimilarToProfile(seeds map[uint]float64, exclude map[uint]bool, limit int) ([]ProfileMatch, error) {
+	if len(seeds) == 0 {
+		return nil, nil
+	}
+	vectors, err := s.vectors.get(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Seeds in ID order, so the profile dimension does not depend on map order
+	ids := make([]uint, 0, len(seeds))
+	for id := range seeds {
+		if _, ok := vectors[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	slices.Sort(ids)
+
+	dim := len(vectors[ids[0]].vec)
+	profile := make([]float64, dim)
+	seedVecs := make(map[uint][]float32, len(ids))
+	for _, id := range ids {
+		v := vectors[id].vec
+		if len(v) != dim {
+			continue
+		}
+		seedVecs[id] = v
+		for i, f := range v {
+			profile[i] += seeds[id] * float64(f)
+		}
+	}
+	profileVec := normalize(profile)
+	if profileVec == nil {
+		return nil, nil
+	}
+
+	var matches []ProfileMatch
+	for id, av := range vectors {
+		if _, isSeed := seeds[id]; isSeed || exclude[id] || len(av.vec) != dim {
+			continue
+		}
+		m := ProfileMatch{ArticleID: id, Similarity: dot(profileVec, av.vec)}
+		best := math.Inf(-1)
+		for seedID, sv := range seedVecs {
+			if sim := dot(sv, av.vec); sim > best || sim == best && seedID < m.SeedID {
+				best, m.SeedID = sim, seedID
+			}
+		}
+		matches = append(matches, m)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].ArticleID < matches[j].ArticleID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// meanVector averages the chunk embeddings and scales the result to unit
+// length, so a dot product of two means is their cosine similarity.
+func meanVector(chunks []ChunkData) []float32 {
+	var sum []float64
+	n := 0
+	for _, c := range chunks {
+		if len(c.Embedding) == 0 {
+			continue
+		}
+		if sum == nil {
+			sum = make([]float64, len(c.Embedding))
+		}
+		if len(c.Embedding) != len(sum) {
+			continue
+		}
+		for i, v := range c.Embedding {
+			sum[i] += float64(v)
+		}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	return normalize(sum)
+}
+
+// normalize scales v to unit length. It returns nil for the zero vector.
+func normalize(v []float64) []float32 {
+	norm := 0.0
+	for _, f := range v {
+		norm += f * f
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return nil
+	}
+
+	out := make([]float32, len(v))
+	for i, f := range v {
+		out[i] = float32(f / norm)
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
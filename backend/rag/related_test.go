@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestVectorService serves the given vectors from a freshly loaded cache,
+// so no database is needed.
+func newTestVectorService(vectors map[uint]articleVector) *RagService {
+	s := &RagService{}
+	s.vectors.byID, s.vectors.loadedAt = vectors, time.Now()
+	return s
+}
+
+var testVectors = map[uint]articleVector{
+	1: {authorID: 1, vec: []float32{1, 0, 0}},
+	2: {authorID: 1, vec: []float32{0.8, 0.6, 0}},
+	3: {authorID: 2, vec: []float32{0.6, 0.8, 0}},
+	4: {authorID: 2, vec: []float32{0, 0, 1}},
+	5: {authorID: 3, vec: []float32{1, 0}}, // Another model's dimension
+}
+
+func relatedIDs(related []RelatedArticle) []uint {
+	var ids []uint
+	for _, r := range related {
+		ids = append(ids, r.ArticleID)
+	}
+	return ids
+}
+
+func TestRelatedArticles(t *testing.T) {
+	s := newTestVectorService(testVectors)
+
+	tests := []struct {
+		name          string
+		article       uint
+		limit         int
+		excludeAuthor bool
+		want          []uint
+	}{
+		{"by similarity, other dimensions skipped", 1, 10, false, []uint{2, 3, 4}},
+		{"limit", 1, 1, false, []uint{2}},
+		{"exclude author", 1, 10, true, []uint{3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			related, err := s.RelatedArticles(tt.article, tt.limit, tt.excludeAuthor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relatedIDs(related); !slices.Equal(got, tt.want) {
+				t.Errorf("related = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	related, err := s.RelatedArticles(99, 10, false)
+	if err != nil || related != nil {
+		t.Errorf("not indexed = %v, %v, want nil", related, err)
+	}
+}
+
+func TestArticleVectorsUpdate(t *testing.T) {
+	s := newTestVectorService(testVectors)
+	before, _ := s.vectors.get(nil)
+
+	s.vectors.put(6, articleVector{authorID: 3, vec: []float32{0.9, 0.1, 0}})
+	s.vectors.remove(2)
+	related, err := s.RelatedArticles(1, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relatedIDs(related); !slices.Equal(got, []uint{6, 3}) {
+		t.Errorf("related after update = %v, want [6 3]", got)
+	}
+	// Maps already handed out are never modified
+	if _, ok := before[2]; !ok || len(before) != len(testVectors) {
+		t.Error("update modified a map handed out earlier")
+	}
+
+	// Nothing is cached before the first load
+	var empty articleVectors
+	empty.put(1, articleVector{})
+	if empty.byID != nil {
+		t.Error("put before the first load cached a partial map")
+	}
+}
@@ -0,0 +1,216 @@
+package rag
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"coin-wave/search"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Reranker scores candidate passages against a query. The returned scores are
+// aligned with docs, higher is more relevant.
+type Reranker interface {
+	Rerank(query string, docs []string) ([]float64, error)
+}
+
+// NewReranker builds the reranker selected by RerankProvider.
+func NewReranker() Reranker {
+	switch RerankProvider {
+	case "api":
+		if RerankBaseURL == "" {
+			log.Println("[RAG] RERANK_BASE_URL is not set, using lexical reranker")
+			return LexicalReranker{}
+		}
+		return NewAPIReranker()
+	case "none":
+		return nil
+	case "", "lexical":
+	default:
+		log.Printf("[RAG] Unknown rerank provider %q, using lexical reranker", RerankProvider)
+	}
+	return LexicalReranker{}
+}
+
+// APIReranker calls a cross-encoder rerank endpoint with the request and
+// response shape shared by Jina, Cohere, Xinference and TEI-compatible servers.
+type APIReranker struct {
+	client *resty.Client
+}
+
+type rerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+type rerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+func NewAPIReranker() *APIReranker {
+	c := resty.New().
+		SetHeader("Content-Type", "application/json").
+		SetTimeout(30 * time.Second)
+	if RerankAPIKey != "" {
+		c.SetHeader("Authorization", "Bearer "+RerankAPIKey)
+	}
+	return &APIReranker{client: c}
+}
+
+func (r *APIReranker) Rerank(query string, docs []string) ([]float64, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var respBody rerankResponse
+	resp, err := r.client.R().
+		SetBody(rerankRequest{Model: RerankModel, Query: query, Documents: docs, TopN: len(docs)}).
+		SetResult(&respBody).
+		Post(RerankBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("rerank api error: %s", resp.String())
+	}
+	if respBody.Error != nil {
+		return nil, fmt.Errorf("rerank api error: %s", respBody.Error.Message)
+	}
+
+	// Documents missing from the response rank last
+	scores := make([]float64, len(docs))
+	for i := range scores {
+		scores[i] = -1
+	}
+	for _, res := range respBody.Results {
+		if res.Index >= 0 && res.Index < len(scores) {
+			scores[res.Index] = res.RelevanceScore
+		}
+	}
+	return scores, nil
+}
+
+// LexicalReranker is the local fallback: the share of distinct query terms
+// that occur in the passage, using the same tokenizer as keyword search.
+type LexicalReranker struct{}
+
+func (LexicalReranker) Rerank(query string, docs []string) ([]float64, error) {
+	queryTerms := termSet(query)
+	scores := make([]float64, len(docs))
+	if len(queryTerms) == 0 {
+		return scores, nil
+	}
+	for i, doc := range docs {
+		docTerms := termSet(doc)
+		matched := 0
+		for term := range queryTerms {
+			if docTerms[term] {
+				matched++
+			}
+		}
+		scores[i] = float64(matched) / float64(len(queryTerms))
+	}
+	return scores, nil
+}
+
+// rerank orders candidates by reranker score, falling back to the lexical
+// reranker if the configured one fails. Candidates keep their fused order on
+// ties, and when reranking is disabled.
+func (s *RagService) rerank(query string, candidates []SearchResult) []SearchResult {
+	if s.reranker == nil || len(candidates) == 0 {
+		return candidates
+	}
+
+	docs := make([]string, len(candidates))
+	for i, c := range candidates {
+		docs[i] = c.Content
+	}
+	scores, err := s.reranker.Rerank(query, docs)
+	if err != nil {
+		log.Printf("[RAG] Rerank failed, using lexical fallback: %v", err)
+		scores, _ = LexicalReranker{}.Rerank(query, docs)
+	}
+
+	type scored struct {
+		res   SearchResult
+		score float64
+	}
+	ranked := make([]scored, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = scored{c, scores[i]}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	out := make([]SearchResult, len(ranked))
+	for i, r := range ranked {
+		out[i] = r.res
+		out[i].Score = float32(r.score)
+	}
+	return out
+}
+
+// selectContext walks ranked candidates and keeps those that fit in
+// RagContextTokens, skipping near-duplicates of an already kept chunk (such as
+// the overlapping tails of neighbouring chunks). At most RagTopK are kept.
+func selectContext(ranked []SearchResult) []SearchResult {
+	var selected []SearchResult
+	var selectedTerms []map[string]bool
+	budget := RagContextTokens
+
+	for _, res := range ranked {
+		if len(selected) >= RagTopK {
+			break
+		}
+		tokens := EstimateTokens(res.Content)
+		if tokens > budget {
+			continue // A shorter chunk further down may still fit
+		}
+
+		terms := termSet(res.Content)
+		duplicate := false
+		for _, kept := range selectedTerms {
+			if jaccard(terms, kept) > RagDuplicateThreshold {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		selected = append(selected, res)
+		selectedTerms = append(selectedTerms, terms)
+		budget -= tokens
+	}
+	return selected
+}
+
+func termSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, term := range search.Tokenize(text) {
+		set[term] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	inter := 0
+	for term := range a {
+		if b[term] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
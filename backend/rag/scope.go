@@ -0,0 +1,100 @@
+package rag
+
+import (
+	"fmt"
+
+	"coin-wave/models"
+
+	"gorm.io/gorm"
+)
+
+// Retrieval scopes decide which articles a query may read from.
+const (
+	ScopeOwn           = "own"            // Articles the user wrote
+	ScopePurchased     = "purchased"      // Articles the user bought
+	ScopeBookmarked    = "bookmarked"     // Bookmarked articles the user can read
+	ScopePublicFree    = "public_free"    // Free articles of any author
+	ScopeAllAccessible = "all_accessible" // Everything the user can read
+)
+
+func IsValidScope(scope string) bool {
+	switch scope {
+	case "", ScopeOwn, ScopePurchased, ScopeBookmarked, ScopePublicFree, ScopeAllAccessible:
+		return true
+	}
+	return false
+}
+
+// scopeFilter resolves a scope into a search filter. Access follows the same
+// rules as GetArticle: free articles, the user's own articles and purchased
+// articles are readable, other paid articles never are.
+//
+// Scopes of up to RagScopeMaxArticles articles are listed in the filter.
+// Larger ones get an empty filter and the query selecting their articles,
+// for inScope to check the candidates against.
+func (s *RagService) scopeFilter(userID uint, scope string) (SearchFilter, *gorm.DB, error) {
+	purchased := s.db.Model(&models.Purchase{}).Select("article_id").Where("user_id = ?", userID)
+	articles := s.db.Model(&models.Article{})
+
+	switch scope {
+	case "", ScopeOwn:
+		return SearchFilter{UserID: userID}, nil, nil
+	case ScopePurchased:
+		articles = articles.Where("id IN (?)", purchased)
+	case ScopeBookmarked:
+		bookmarked := s.db.Model(&models.Bookmark{}).Select("article_id").Where("user_id = ?", userID)
+		articles = articles.Where("id IN (?)", bookmarked).
+			Where("is_paid = ? OR author_id = ? OR id IN (?)", false, userID, purchased)
+	case ScopePublicFree:
+		articles = articles.Where("is_paid = ?", false)
+	case ScopeAllAccessible:
+		articles = articles.Where("is_paid = ? OR author_id = ? OR id IN (?)", false, userID, purchased)
+	default:
+		return SearchFilter{}, nil, fmt.Errorf("invalid scope: %s", scope)
+	}
+	articles = articles.Session(&gorm.Session{})
+
+	var ids []uint
+	if err := articles.Limit(RagScopeMaxArticles+1).Pluck("id", &ids).Error; err != nil {
+		return SearchFilter{}, nil, err
+	}
+	if len(ids) > RagScopeMaxArticles {
+		return SearchFilter{}, articles, nil
+	}
+	// Non-nil so an empty result means "no articles" rather than "any article"
+	if ids == nil {
+		ids = []uint{}
+	}
+	return SearchFilter{ArticleIDs: ids}, nil, nil
+}
+
+// inScope keeps the results whose article is selected by the articles query
+// of a scope, in their order.
+func inScope(articles *gorm.DB, results []SearchResult) ([]SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, r := range results {
+		if !seen[r.ArticleID] {
+			seen[r.ArticleID] = true
+			ids = append(ids, r.ArticleID)
+		}
+	}
+	var allowed []int64
+	if err := articles.Where("id IN ?", ids).Pluck("id", &allowed).Error; err != nil {
+		return nil, err
+	}
+	ok := make(map[int64]bool, len(allowed))
+	for _, id := range allowed {
+		ok[id] = true
+	}
+	kept := results[:0:0]
+	for _, r := range results {
+		if ok[r.ArticleID] {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
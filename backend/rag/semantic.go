@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+)
+
+// SemanticCandidates is the minimum number of chunks fetched per semantic
+// search before they are grouped per article. At least
+// semanticChunksPerArticle chunks are fetched per requested article.
+const (
+	SemanticCandidates       = 100
+	semanticChunksPerArticle = 4
+)
+
+// ArticleMatch is an article found by semantic search with its best chunk.
+type ArticleMatch struct {
+	ArticleID  uint
+	ChunkIndex int     // Best matching chunk
+	Content    string  // Content of the best matching chunk
+	Distance   float32 // Vector distance of the best chunk, lower is closer
+	Matches    int     // Matching chunks among the candidates
+}
+
+// SemanticSearch embeds the query and returns up to limit articles whose
+// chunks are closest to it, among the articles the user can read (free ones
+// only for userID 0). Other paid articles are filtered out in the store, so
+// they do not take up the candidates.
+func (s *RagService) SemanticSearch(ctx context.Context, userID uint, query string, limit int) ([]ArticleMatch, error) {
+	filter, articles, err := s.scopeFilter(userID, ScopeAllAccessible)
+	if err != nil {
+		return nil, err
+	}
+	if filter.ArticleIDs != nil && len(filter.ArticleIDs) == 0 {
+		return nil, nil
+	}
+
+	embeddings, err := s.embedder.GetEmbeddings([]string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("failed to embed query")
+	}
+
+	fetch := max(SemanticCandidates, limit*semanticChunksPerArticle)
+	if articles != nil {
+		fetch *= RagScopeOversample
+	}
+	results, err := s.store.Search(ctx, filter, embeddings[0], fetch)
+	if err != nil {
+		return nil, err
+	}
+	if articles != nil {
+		if results, err = inScope(articles, results); err != nil {
+			return nil, err
+		}
+	}
+
+	// Results come closest first, so an article's first hit is its best
+	var matches []ArticleMatch
+	index := make(map[uint]int)
+	for _, res := range results {
+		id := uint(res.ArticleID)
+		if i, ok := index[id]; ok {
+			matches[i].Matches++
+			continue
+		}
+		index[id] = len(matches)
+		matches = append(matches, ArticleMatch{
+			ArticleID:  id,
+			ChunkIndex: int(res.ChunkIndex),
+			Content:    res.Content,
+			Distance:   res.Score,
+			Matches:    1,
+		})
+	}
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
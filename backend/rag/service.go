@@ -2,6 +2,7 @@ package rag
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -13,180 +14,194 @@ import (
 )
 
 type RagService struct {
-	db          *gorm.DB
-	volcClient  *VolcClient
-	milvusStore *MilvusStore
+	db       *gorm.DB
+	embedder Embedder
+	chat     ChatModel
+	store    VectorStore
+	chunker  Chunker
+	keywords *KeywordRetriever
+	reranker Reranker // nil disables reranking
+	progress *ProgressHub
+	vectors  articleVectors
+
+	indexHooks []func(articleID uint)
 }
 
-func NewRagService(db *gorm.DB, volc *VolcClient, milvus *MilvusStore) *RagService {
+func NewRagService(db *gorm.DB, embedder Embedder, chat ChatModel, store VectorStore) *RagService {
 	return &RagService{
-		db:          db,
-		volcClient:  volc,
-		milvusStore: milvus,
+		db:       db,
+		embedder: embedder,
+		chat:     chat,
+		store:    store,
+		chunker:  NewChunker(ChunkStrategy),
+		keywords: NewKeywordRetriever(db),
+		reranker: NewReranker(),
+		progress: NewProgressHub(),
 	}
 }
 
-// ProcessArticle chunks the article, embeds it, and stores it in Milvus.
-func (s *RagService) ProcessArticle(ctx context.Context, articleID uint) error {
-	var article models.Article
-	if err := s.db.First(&article, articleID).Error; err != nil {
-		return err
-	}
-
-	// Update status to processing
-	s.db.Model(&article).Update("vector_status", "processing")
-
-	// 1. Chunking
-	chunks := s.chunkText(article.Content, ChunkSize, ChunkOverlap)
-
-	// 2. Prepare texts for embedding
-	// Format: Title + Tags + Content
-	var textsToEmbed []string
-	var chunkDataList []ChunkData
-
-	for i, content := range chunks {
-		text := fmt.Sprintf("%s %s\n%s", article.Title, article.Tags, content)
-		textsToEmbed = append(textsToEmbed, text)
-		chunkDataList = append(chunkDataList, ChunkData{
-			UserID:     article.AuthorID,
-			ArticleID:  article.ID,
-			ChunkIndex: i,
-			Content:    content,
-			// Embedding will be filled later
-		})
-	}
-
-	// 3. Batch Embedding
-	// Max batch size for Doubao API is usually limited (e.g. 20 or 50).
-	// We should batch requests.
-	batchSize := 10
-	for i := 0; i < len(textsToEmbed); i += batchSize {
-		end := i + batchSize
-		if end > len(textsToEmbed) {
-			end = len(textsToEmbed)
-		}
-
-		batchTexts := textsToEmbed[i:end]
-		log.Printf("Embedding batch %d-%d for article %d", i, end, articleID) // Debug Log
-		embeddings, err := s.volcClient.GetEmbeddings(batchTexts)
-		if err != nil {
-			log.Printf("Embedding failed: %v", err)
-			s.db.Model(&article).Update("vector_status", "failed")
-			return err
-		}
+// LoadKeywordIndex builds the BM25 index from the stored chunks.
+func (s *RagService) LoadKeywordIndex() error {
+	return s.keywords.Load()
+}
 
-		// Fill embeddings back to chunkDataList
-		for j, emb := range embeddings {
-			chunkDataList[i+j].Embedding = emb
-		}
+// storeChunks replaces everything indexed for an article with the given chunks,
+// so re-indexing never leaves duplicate or stale vectors behind.
+func (s *RagService) storeChunks(ctx context.Context, articleID uint, chunks []ChunkData) error {
+	if err := s.DeleteArticleVectors(ctx, articleID); err != nil {
+		return err
 	}
 
-	// 4. Insert to Milvus
-	ids, err := s.milvusStore.InsertChunks(ctx, chunkDataList)
+	ids, err := s.store.InsertChunks(ctx, chunks)
 	if err != nil {
-		s.db.Model(&article).Update("vector_status", "failed")
 		return err
 	}
 
-	// 5. Save Chunks to DB (Optional, but requested)
 	var dbChunks []models.Chunk
-	for i, c := range chunkDataList {
+	for i, c := range chunks {
 		var vid int64
 		if i < len(ids) {
 			vid = ids[i]
 		}
 		dbChunks = append(dbChunks, models.Chunk{
-			ArticleID:  c.ArticleID,
-			Content:    c.Content,
-			ChunkIndex: c.ChunkIndex,
-			VectorID:   vid,
+			ArticleID:   c.ArticleID,
+			Content:     c.Content,
+			ChunkIndex:  c.ChunkIndex,
+			VectorID:    vid,
+			StartOffset: c.StartOffset,
+			EndOffset:   c.EndOffset,
+			Breadcrumb:  c.Breadcrumb,
 		})
 	}
-	// Use batch insert
 	if len(dbChunks) > 0 {
 		if err := s.db.Create(&dbChunks).Error; err != nil {
-			log.Printf("Failed to save chunks to DB: %v", err)
-			// Don't fail the whole process if DB save fails, but good to know
+			// Vectors without chunk rows would be orphaned, roll them back
+			s.store.DeleteByArticle(ctx, articleID)
+			return err
 		}
 	}
+	if err := s.storeArticleEmbedding(articleID, chunks); err != nil {
+		log.Printf("[RAG] Failed to store article embedding for article %d: %v", articleID, err)
+	}
+	if err := s.keywords.IndexArticle(articleID); err != nil {
+		log.Printf("[RAG] Failed to update keyword index for article %d: %v", articleID, err)
+	}
+	s.notifyIndexChange(articleID)
+	return nil
+}
 
-	s.db.Model(&article).Update("vector_status", "completed")
+// DeleteArticleVectors removes an article's vectors, chunk rows and article embedding.
+func (s *RagService) DeleteArticleVectors(ctx context.Context, articleID uint) error {
+	if err := s.store.DeleteByArticle(ctx, articleID); err != nil {
+		return err
+	}
+	s.keywords.RemoveArticle(articleID)
+	if err := s.deleteArticleEmbedding(articleID); err != nil {
+		return err
+	}
+	if err := s.db.Unscoped().Where("article_id = ?", articleID).Delete(&models.Chunk{}).Error; err != nil {
+		return err
+	}
+	s.notifyIndexChange(articleID)
 	return nil
 }
 
-func (s *RagService) chunkText(text string, chunkSize, overlap int) []string {
-	// Simple character-based chunking for now.
-	// Production systems should use token-based chunking.
-	// Assuming 1 char ~= 1 token for simplicity or use a library if available.
-	// Since we don't have a tokenizer lib in dependencies, we'll use simple rune counting.
-	
-	runes := []rune(text)
-	var chunks []string
-	
-	if len(runes) == 0 {
-		return chunks
-	}
-
-	for i := 0; i < len(runes); i += (chunkSize - overlap) {
-		end := i + chunkSize
-		if end > len(runes) {
-			end = len(runes)
-		}
-		chunks = append(chunks, string(runes[i:end]))
-		if end == len(runes) {
-			break
-		}
+// ReloadArticle re-reads what this process keeps in memory about an article,
+// its keyword index entries and its embedding, after another replica
+// re-indexed or deleted it.
+func (s *RagService) ReloadArticle(articleID uint) error {
+	if err := s.keywords.IndexArticle(articleID); err != nil {
+		return err
 	}
-	return chunks
+	var e models.ArticleEmbedding
+	err := s.db.Where("article_id = ?", articleID).First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.vectors.remove(articleID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.vectors.put(articleID, articleVector{authorID: e.AuthorID, vec: decodeVector(e.Vector)})
+	return nil
+}
+
+// embeddingText prefixes a chunk with the article title, tags and its heading
+// breadcrumb, so a chunk deep in a section still matches questions about it.
+func embeddingText(article *models.Article, chunk TextChunk) string {
+	if chunk.Breadcrumb == "" {
+		return fmt.Sprintf("%s %s\n%s", article.Title, article.Tags, chunk.Content)
+	}
+	return fmt.Sprintf("%s %s\n%s\n%s", article.Title, article.Tags, chunk.Breadcrumb, chunk.Content)
 }
 
-func (s *RagService) Query(ctx context.Context, userID uint, question string) (string, []string, map[string]float64, error) {
+// QueryOptions tunes a RAG query.
+type QueryOptions struct {
+	History []Message // Previous turns of the conversation, oldest first
+	Scope   string    // Retrieval scope, defaults to ScopeOwn
+}
+
+// Query answers a question from the articles in the requested scope.
+// The answer cites sources with [n] markers, which are resolved in the returned citations.
+func (s *RagService) Query(ctx context.Context, userID uint, question string, opts QueryOptions) (string, []Citation, map[string]float64, error) {
 	timings := make(map[string]float64)
 	start := time.Now()
+	history := windowHistory(opts.History)
 
-	// 1. Embed question
-	embedStart := time.Now()
-	embeddings, err := s.volcClient.GetEmbeddings([]string{question})
+	// 1. Rewrite follow-up questions into standalone ones
+	searchQuery, err := s.rewriteQuestion(history, question, timings)
 	if err != nil {
 		return "", nil, nil, err
 	}
-	timings["embedding"] = time.Since(embedStart).Seconds()
 
-	if len(embeddings) == 0 {
-		return "", nil, nil, fmt.Errorf("failed to embed question")
+	// 2-3. Embed question and search
+	citations, err := s.retrieve(ctx, userID, opts.Scope, searchQuery, timings)
+	if err != nil {
+		return "", nil, nil, err
 	}
 
-	// 2. Search Milvus
-	searchStart := time.Now()
-	results, err := s.milvusStore.Search(ctx, userID, embeddings[0], 5) // Top 5
+	// 4. Call LLM
+	llmStart := time.Now()
+	answer, err := s.chat.Chat(buildMessages(history, question, citations))
 	if err != nil {
 		return "", nil, nil, err
 	}
-	timings["search"] = time.Since(searchStart).Seconds()
+	timings["llm"] = time.Since(llmStart).Seconds()
 
-	// 3. Construct Prompt
-	var retrievedChunks []string
-	for _, res := range results {
-		retrievedChunks = append(retrievedChunks, res.Content)
-	}
+	timings["total_internal"] = time.Since(start).Seconds()
 
-	contextText := strings.Join(retrievedChunks, "\n\n")
-	
-	systemPrompt := fmt.Sprintf(`你是用户的私人知识助理。以下内容来自用户自己的文章：
-%s
+	return answer, markCited(answer, citations), timings, nil
+}
 
-用户问题：
-%s
+// QueryStream is the streaming variant of Query. It emits a "sources" event
+// once retrieval is done and a "delta" event per answer token, then returns
+// the same values as Query. Cancelling ctx aborts the LLM call.
+func (s *RagService) QueryStream(ctx context.Context, userID uint, question string, opts QueryOptions, emit func(event string, data interface{}) error) (string, []Citation, map[string]float64, error) {
+	timings := make(map[string]float64)
+	start := time.Now()
+	history := windowHistory(opts.History)
 
-请严格根据文章内容回答，不允许凭空生成。`, contextText, question)
+	searchQuery, err := s.rewriteQuestion(history, question, timings)
+	if err != nil {
+		return "", nil, nil, err
+	}
 
-	// 4. Call LLM
-	llmStart := time.Now()
-	messages := []Message{
-		{Role: "user", Content: systemPrompt},
+	citations, err := s.retrieve(ctx, userID, opts.Scope, searchQuery, timings)
+	if err != nil {
+		return "", nil, nil, err
 	}
-	
-	answer, err := s.volcClient.Chat(messages)
+	if err := emit("sources", map[string]interface{}{
+		"sources":   SourceTexts(citations),
+		"citations": citations,
+		"timings":   timings,
+	}); err != nil {
+		return "", nil, nil, err
+	}
+
+	llmStart := time.Now()
+	answer, err := s.chat.ChatStream(ctx, buildMessages(history, question, citations), func(delta string) error {
+		return emit("delta", map[string]string{"content": delta})
+	})
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -194,5 +209,135 @@ func (s *RagService) Query(ctx context.Context, userID uint, question string) (s
 
 	timings["total_internal"] = time.Since(start).Seconds()
 
-	return answer, retrievedChunks, timings, nil
+	return answer, markCited(answer, citations), timings, nil
+}
+
+// windowHistory keeps the most recent HistoryWindow messages.
+func windowHistory(history []Message) []Message {
+	if len(history) > HistoryWindow {
+		return history[len(history)-HistoryWindow:]
+	}
+	return history
+}
+
+// rewriteQuestion turns a follow-up question into a standalone search query
+// using the conversation history. Without history the question is returned as is.
+func (s *RagService) rewriteQuestion(history []Message, question string, timings map[string]float64) (string, error) {
+	if len(history) == 0 {
+		return question, nil
+	}
+
+	rewriteStart := time.Now()
+	var transcript strings.Builder
+	for _, m := range history {
+		fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+	}
+	prompt := fmt.Sprintf(`根据以下对话历史，把用户的最新问题改写成一个不依赖上下文、可以独立用于检索的问题。
+只输出改写后的问题，不要回答问题，不要添加解释。
+
+对话历史：
+%s
+最新问题：
+%s`, transcript.String(), question)
+
+	rewritten, err := s.chat.Chat([]Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		return "", err
+	}
+	timings["rewrite"] = time.Since(rewriteStart).Seconds()
+
+	rewritten = strings.TrimSpace(rewritten)
+	if rewritten == "" {
+		return question, nil
+	}
+	return rewritten, nil
+}
+
+// retrieve returns the chunks within the scope that best match the question:
+// vector and BM25 keyword candidates are fused, reranked, and trimmed to the
+// context budget.
+func (s *RagService) retrieve(ctx context.Context, userID uint, scope string, question string, timings map[string]float64) ([]Citation, error) {
+	filter, articles, err := s.scopeFilter(userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	if filter.ArticleIDs != nil && len(filter.ArticleIDs) == 0 {
+		return nil, nil // Nothing readable in this scope
+	}
+	fetch := RagCandidates
+	if articles != nil {
+		fetch *= RagScopeOversample
+	}
+
+	embedStart := time.Now()
+	embeddings, err := s.embedder.GetEmbeddings([]string{question})
+	if err != nil {
+		return nil, err
+	}
+	timings["embedding"] = time.Since(embedStart).Seconds()
+
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("failed to embed question")
+	}
+
+	searchStart := time.Now()
+	vectorHits, err := s.store.Search(ctx, filter, embeddings[0], fetch)
+	if err != nil {
+		return nil, err
+	}
+	if articles != nil {
+		if vectorHits, err = inScope(articles, vectorHits); err != nil {
+			return nil, err
+		}
+	}
+	timings["search"] = time.Since(searchStart).Seconds()
+
+	var keywordHits []SearchResult
+	if RagFusionKeywordWeight > 0 {
+		keywordStart := time.Now()
+		keywordHits, err = s.keywords.Search(filter, question, fetch)
+		if err != nil {
+			return nil, err
+		}
+		if articles != nil {
+			if keywordHits, err = inScope(articles, keywordHits); err != nil {
+				return nil, err
+			}
+		}
+		timings["keyword"] = time.Since(keywordStart).Seconds()
+	}
+
+	candidates := fuseRRF(
+		[][]SearchResult{vectorHits, keywordHits},
+		[]float64{RagFusionVectorWeight, RagFusionKeywordWeight},
+		RagCandidates,
+	)
+
+	rerankStart := time.Now()
+	results := selectContext(s.rerank(question, candidates))
+	timings["rerank"] = time.Since(rerankStart).Seconds()
+
+	return s.buildCitations(results), nil
+}
+
+func buildMessages(history []Message, question string, citations []Citation) []Message {
+	var contextText strings.Builder
+	for _, c := range citations {
+		if c.Breadcrumb != "" {
+			fmt.Fprintf(&contextText, "[%d] 《%s》 %s\n%s\n\n", c.Marker, c.Title, c.Breadcrumb, c.Content)
+		} else {
+			fmt.Fprintf(&contextText, "[%d] 《%s》\n%s\n\n", c.Marker, c.Title, c.Content)
+		}
+	}
+
+	systemPrompt := fmt.Sprintf(`你是用户的私人知识助理。以下内容来自用户可以阅读的文章，每段前的 [编号] 是它的来源编号：
+%s
+用户问题：
+%s
+
+请严格根据文章内容回答，不允许凭空生成。引用某段内容时，请在句末用对应的来源编号标注，例如 [1] 或 [1][2]。`, contextText.String(), question)
+
+	messages := make([]Message, 0, len(history)+1)
+	messages = append(messages, history...)
+	return append(messages, Message{Role: "user", Content: systemPrompt})
 }
@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+)
+
+// VectorStore persists chunk embeddings and answers nearest-neighbour queries.
+// Scores are L2 distances, lower is closer.
+type VectorStore interface {
+	InitCollection(ctx context.Context) error
+	InsertChunks(ctx context.Context, chunks []ChunkData) ([]int64, error)
+	Search(ctx context.Context, filter SearchFilter, queryVector []float32, topK int) ([]SearchResult, error)
+	DeleteByArticle(ctx context.Context, articleID uint) error
+}
+
+// SearchFilter restricts which chunks a search may return. Zero values mean no restriction.
+type SearchFilter struct {
+	UserID     uint   // Only chunks of articles written by this user
+	ArticleIDs []uint // Only chunks of these articles (nil = any article)
+}
+
+type ChunkData struct {
+	UserID      uint
+	ArticleID   uint
+	ChunkIndex  int
+	Content     string
+	StartOffset int // Rune offsets into the article content
+	EndOffset   int
+	Breadcrumb  string // Heading path of the chunk, kept in MySQL only
+	Embedding   []float32
+}
+
+type SearchResult struct {
+	ID         int64
+	Score      float32
+	Content    string
+	ArticleID  int64
+	ChunkIndex int64
+}
+
+// NewVectorStore builds the store selected by VectorStoreType.
+func NewVectorStore(ctx context.Context) (VectorStore, error) {
+	switch VectorStoreType {
+	case "", "milvus":
+		return NewMilvusStore(ctx)
+	case "local":
+		return NewLocalStore(LocalStorePath), nil
+	default:
+		return nil, fmt.Errorf("unknown vector store: %s", VectorStoreType)
+	}
+}
@@ -0,0 +1,32 @@
+package rag
+
+import (
+	"unicode"
+
+	"coin-wave/search"
+)
+
+// EstimateTokens approximates the token count of text without a tokenizer:
+// every CJK character counts as one token, other runs of non-space characters
+// as one token per 4 characters (rounded up).
+func EstimateTokens(text string) int {
+	tokens := 0
+	run := 0
+	flush := func() {
+		tokens += (run + 3) / 4
+		run = 0
+	}
+	for _, r := range text {
+		switch {
+		case search.IsCJK(r):
+			flush()
+			tokens++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			run++
+		}
+	}
+	flush()
+	return tokens
+}
@@ -25,7 +25,7 @@ type IngestionOutput struct {
 
 type ArticleWithChunks struct {
 	Article *models.Article
-	Chunks  []string
+	Chunks  []TextChunk
 }
 
 type ArticleWithEmbeddings struct {
@@ -53,19 +53,16 @@ func NewIngestionWorker(service *RagService) (*IngestionWorker, error) {
 			log.Printf("[Worker] Failed to fetch article %d: %v", input.ArticleID, err)
 			return nil, err
 		}
-		service.db.Model(&article).Updates(map[string]interface{}{
-			"vector_status":   "processing",
-			"vector_progress": 10,
-		})
+		service.reportProgress(article.ID, "processing", StageFetch, 10, "")
 		return &article, nil
 	})
 
 	// Node 2: Chunking
 	chunkNode := compose.InvokableLambda(func(ctx context.Context, article *models.Article) (*ArticleWithChunks, error) {
 		log.Printf("[Worker] Chunking article %d", article.ID)
-		chunks := service.chunkText(article.Content, ChunkSize, ChunkOverlap)
+		chunks := service.chunker.Chunk(article.Content)
 		log.Printf("[Worker] Generated %d chunks for article %d", len(chunks), article.ID)
-		service.db.Model(article).Update("vector_progress", 20)
+		service.reportProgress(article.ID, "processing", StageChunk, 20, "")
 		return &ArticleWithChunks{Article: article, Chunks: chunks}, nil
 	})
 
@@ -75,14 +72,16 @@ func NewIngestionWorker(service *RagService) (*IngestionWorker, error) {
 		var textsToEmbed []string
 		var chunkDataList []ChunkData
 
-		for i, content := range input.Chunks {
-			text := fmt.Sprintf("%s %s\n%s", input.Article.Title, input.Article.Tags, content)
-			textsToEmbed = append(textsToEmbed, text)
+		for i, chunk := range input.Chunks {
+			textsToEmbed = append(textsToEmbed, embeddingText(input.Article, chunk))
 			chunkDataList = append(chunkDataList, ChunkData{
-				UserID:     input.Article.AuthorID,
-				ArticleID:  input.Article.ID,
-				ChunkIndex: i,
-				Content:    content,
+				UserID:      input.Article.AuthorID,
+				ArticleID:   input.Article.ID,
+				ChunkIndex:  i,
+				Content:     chunk.Content,
+				StartOffset: chunk.Start,
+				EndOffset:   chunk.End,
+				Breadcrumb:  chunk.Breadcrumb,
 			})
 		}
 
@@ -97,26 +96,27 @@ func NewIngestionWorker(service *RagService) (*IngestionWorker, error) {
 			}
 
 			batchTexts := textsToEmbed[i:end]
-			embeddings, err := service.volcClient.GetEmbeddings(batchTexts)
+			embeddings, err := service.embedder.GetEmbeddings(batchTexts)
 			if err != nil {
 				log.Printf("Embedding failed for chunk batch %d-%d: %v", i, end, err)
 				return nil, err
 			}
 
-			// If embeddings are fewer than requested (should not happen with robust client)
-			if len(embeddings) < len(batchTexts) {
-				log.Printf("Warning: received fewer embeddings (%d) than requested (%d)", len(embeddings), len(batchTexts))
+			// A short response would store chunks without vectors, fail so the job is retried
+			if len(embeddings) != len(batchTexts) {
+				return nil, fmt.Errorf("received %d embeddings for %d chunks", len(embeddings), len(batchTexts))
 			}
 
 			for j, emb := range embeddings {
-				if i+j < len(chunkDataList) {
-					chunkDataList[i+j].Embedding = emb
+				if len(emb) == 0 {
+					return nil, fmt.Errorf("received no embedding for chunk %d", i+j)
 				}
+				chunkDataList[i+j].Embedding = emb
 			}
 
 			// Update Progress: 20 -> 90
 			progress := 20 + int(math.Round(float64(end)/float64(totalChunks)*70))
-			service.db.Model(input.Article).Update("vector_progress", progress)
+			service.reportProgress(input.Article.ID, "processing", StageEmbed, progress, "")
 		}
 
 		return &ArticleWithEmbeddings{Article: input.Article, Chunks: chunkDataList}, nil
@@ -124,40 +124,21 @@ func NewIngestionWorker(service *RagService) (*IngestionWorker, error) {
 
 	// Node 4: Storing
 	storeNode := compose.InvokableLambda(func(ctx context.Context, input *ArticleWithEmbeddings) (IngestionOutput, error) {
-		ids, err := service.milvusStore.InsertChunks(ctx, input.Chunks)
-		if err != nil {
-			service.db.Model(input.Article).Updates(map[string]interface{}{
-				"vector_status":   "failed",
-				"vector_progress": 0,
-			})
-			return IngestionOutput{ArticleID: input.Article.ID, Status: "failed", Error: err}, nil
+		// The article may have been deleted while we were embedding
+		var count int64
+		service.db.Model(&models.Article{}).Where("id = ?", input.Article.ID).Count(&count)
+		if count == 0 {
+			log.Printf("[Worker] Article %d was deleted during ingestion, skipping store", input.Article.ID)
+			return IngestionOutput{ArticleID: input.Article.ID, Status: "skipped"}, nil
 		}
 
-		// Save Chunks to DB
-		// Clear old chunks first to avoid duplication on re-index
-		service.db.Where("article_id = ?", input.Article.ID).Delete(&models.Chunk{})
-
-		var dbChunks []models.Chunk
-		for i, c := range input.Chunks {
-			var vid int64
-			if i < len(ids) {
-				vid = ids[i]
-			}
-			dbChunks = append(dbChunks, models.Chunk{
-				ArticleID:  c.ArticleID,
-				Content:    c.Content,
-				ChunkIndex: c.ChunkIndex,
-				VectorID:   vid,
-			})
-		}
-		if len(dbChunks) > 0 {
-			service.db.Create(&dbChunks)
+		service.reportProgress(input.Article.ID, "processing", StageStore, 90, "")
+		if err := service.storeChunks(ctx, input.Article.ID, input.Chunks); err != nil {
+			// Status is settled by the job queue, which decides whether to retry
+			return IngestionOutput{ArticleID: input.Article.ID, Status: "failed", Error: err}, err
 		}
 
-		service.db.Model(input.Article).Updates(map[string]interface{}{
-			"vector_status":   "completed",
-			"vector_progress": 100,
-		})
+		service.reportProgress(input.Article.ID, "completed", StageDone, 100, "")
 		return IngestionOutput{ArticleID: input.Article.ID, Status: "completed"}, nil
 	})
 
@@ -183,25 +164,12 @@ func NewIngestionWorker(service *RagService) (*IngestionWorker, error) {
 	return &IngestionWorker{runnable: r, service: service}, nil
 }
 
-func (w *IngestionWorker) Run(ctx context.Context, articleID uint) {
-	// Run in background
-	go func() {
-		out, err := w.runnable.Invoke(context.Background(), IngestionInput{ArticleID: articleID})
-		if err != nil {
-			log.Printf("Ingestion failed for article %d: %v", articleID, err)
-			// Mark as failed if not handled inside
-			var article models.Article
-			if err := w.service.db.First(&article, articleID).Error; err == nil {
-				w.service.db.Model(&article).Updates(map[string]interface{}{
-					"vector_status":   "failed",
-					"vector_progress": 0,
-				})
-			}
-		} else if out.Error != nil {
-			log.Printf("Ingestion failed for article %d: %v", articleID, out.Error)
-			// Already handled inside storeNode but safe to double check or log
-		} else {
-			log.Printf("Ingestion completed for article %d", articleID)
-		}
-	}()
+// Invoke runs the ingestion graph for one article synchronously.
+// Scheduling, retries and failure status are handled by JobQueue.
+func (w *IngestionWorker) Invoke(ctx context.Context, articleID uint) error {
+	out, err := w.runnable.Invoke(ctx, IngestionInput{ArticleID: articleID})
+	if err != nil {
+		return err
+	}
+	return out.Error
 }
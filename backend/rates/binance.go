@@ -0,0 +1,91 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"coin-wave/money"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// BinanceProvider quotes crypto prices from the public ticker API, using the
+// USDT pairs as USD prices.
+type BinanceProvider struct {
+	client *resty.Client
+}
+
+func NewBinanceProvider() *BinanceProvider {
+	return &BinanceProvider{client: resty.New().SetBaseURL(BinanceBaseURL).SetTimeout(FetchTimeout)}
+}
+
+func (p *BinanceProvider) Name() string { return "binance" }
+
+func (p *BinanceProvider) Fetch(ctx context.Context, req Request) ([]Quote, error) {
+	now := time.Now()
+	var quotes []Quote
+	var pairs []string
+	symbols := make(map[string]string)
+	for _, s := range req.Crypto {
+		if s == "USDT" {
+			// The quote currency itself
+			quotes = append(quotes, newCryptoQuote(s, money.NewFromInt(1), p.Name(), now))
+			continue
+		}
+		pairs = append(pairs, s+"USDT")
+		symbols[s+"USDT"] = s
+	}
+	if len(pairs) == 0 {
+		return quotes, nil
+	}
+
+	// An unknown pair fails the whole request, so ask for pairs one by one
+	// only if the batch is rejected
+	tickers, err := p.tickers(ctx, pairs)
+	if err != nil {
+		tickers = nil
+		for _, pair := range pairs {
+			t, err := p.tickers(ctx, []string{pair})
+			if err == nil {
+				tickers = append(tickers, t...)
+			}
+		}
+		if len(tickers) == 0 {
+			return quotes, err
+		}
+	}
+
+	for _, t := range tickers {
+		price, ok := parsePrice(t.Price)
+		if !ok {
+			continue
+		}
+		quotes = append(quotes, newCryptoQuote(symbols[t.Symbol], price, p.Name(), now))
+	}
+	return quotes, nil
+}
+
+type binanceTicker struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+func (p *BinanceProvider) tickers(ctx context.Context, pairs []string) ([]binanceTicker, error) {
+	list, _ := json.Marshal(pairs)
+	var tickers []binanceTicker
+	resp, err := p.client.R().
+		SetContext(ctx).
+		SetQueryParam("symbols", string(list)).
+		SetResult(&tickers).
+		Get("/ticker/price")
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("binance error: %s: %s", resp.Status(), strings.TrimSpace(resp.String()))
+	}
+	return tickers, nil
+}
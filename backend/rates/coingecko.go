@@ -0,0 +1,72 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// coinGeckoIDs maps ticker symbols to CoinGecko coin IDs.
+var coinGeckoIDs = map[string]string{
+	"BTC":  "bitcoin",
+	"ETH":  "ethereum",
+	"SOL":  "solana",
+	"BNB":  "binancecoin",
+	"USDT": "tether",
+	"USDC": "usd-coin",
+	"XRP":  "ripple",
+	"DOGE": "dogecoin",
+	"ADA":  "cardano",
+	"TRX":  "tron",
+}
+
+// CoinGeckoProvider quotes crypto prices from the public /simple/price API.
+type CoinGeckoProvider struct {
+	client *resty.Client
+}
+
+func NewCoinGeckoProvider() *CoinGeckoProvider {
+	return &CoinGeckoProvider{client: resty.New().SetBaseURL(CoinGeckoBaseURL).SetTimeout(FetchTimeout)}
+}
+
+func (p *CoinGeckoProvider) Name() string { return "coingecko" }
+
+func (p *CoinGeckoProvider) Fetch(ctx context.Context, req Request) ([]Quote, error) {
+	var ids []string
+	symbols := make(map[string]string)
+	for _, s := range req.Crypto {
+		if id, ok := coinGeckoIDs[s]; ok {
+			ids = append(ids, id)
+			symbols[id] = s
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var body map[string]map[string]json.Number // id -> currency -> price
+	resp, err := p.client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{"ids": strings.Join(ids, ","), "vs_currencies": "usd"}).
+		SetResult(&body).
+		Get("/simple/price")
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("coingecko error: %s", resp.Status())
+	}
+
+	now := time.Now()
+	var quotes []Quote
+	for id, prices := range body {
+		if price, ok := parsePrice(prices["usd"].String()); ok {
+			quotes = append(quotes, newCryptoQuote(symbols[id], price, p.Name(), now))
+		}
+	}
+	return quotes, nil
+}
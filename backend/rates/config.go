@@ -0,0 +1,98 @@
+package rates
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// Provider chain, tried in order for symbols the previous ones did not return:
+	// "coingecko", "binance", "exchangerate" (open.er-api.com) and "fixture".
+	// Use "fixture" alone to run offline.
+	Providers = DefaultProviders
+
+	// Chain used when Providers is invalid, never the fixture
+	DefaultProviders = []string{"coingecko", "binance", "exchangerate"}
+
+	FiatSymbols   = []string{"CNY", "EUR", "GBP", "JPY", "HKD"}
+	CryptoSymbols = []string{"BTC", "ETH", "SOL", "BNB", "USDT"}
+
+	RefreshInterval = time.Minute
+	StaleAfter      = 5 * time.Minute // Quotes not refreshed for this long are flagged stale
+	FetchTimeout    = 10 * time.Second
+
+	// Snapshots are kept for RawRetention, then downsampled into hourly candles,
+	// which are kept for HourlyRetention before becoming daily candles.
+	RawRetention    = 48 * time.Hour
+	HourlyRetention = 90 * 24 * time.Hour
+	MaxCandles      = 1000 // Per history request
+
+	WalletCurrency = "USD"            // Currency user balances are held in
+	QuoteTTL       = 60 * time.Second // How long a checkout price quote stays valid
+
+	FixturePath = "" // JSON file for the fixture provider, empty uses the built-in one
+
+	CoinGeckoBaseURL    = "https://api.coingecko.com/api/v3"
+	BinanceBaseURL      = "https://api.binance.com/api/v3"
+	ExchangeRateBaseURL = "https://open.er-api.com/v6"
+)
+
+func init() {
+	if v := os.Getenv("RATES_PROVIDERS"); v != "" {
+		Providers = splitList(v, false)
+	}
+	if v := os.Getenv("RATES_FIAT"); v != "" {
+		FiatSymbols = splitList(v, true)
+	}
+	if v := os.Getenv("RATES_CRYPTO"); v != "" {
+		CryptoSymbols = splitList(v, true)
+	}
+	if v := os.Getenv("RATES_REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			RefreshInterval = d
+		}
+	}
+	if v := os.Getenv("RATES_STALE_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			StaleAfter = d
+		}
+	}
+	if v := os.Getenv("RATES_RAW_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			RawRetention = d
+		}
+	}
+	if v := os.Getenv("RATES_HOURLY_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			HourlyRetention = d
+		}
+	}
+	if v := os.Getenv("WALLET_CURRENCY"); v != "" {
+		WalletCurrency = strings.ToUpper(v)
+	}
+	if v := os.Getenv("RATES_QUOTE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			QuoteTTL = d
+		}
+	}
+	if v := os.Getenv("RATES_FIXTURE_PATH"); v != "" {
+		FixturePath = v
+	}
+}
+
+func splitList(v string, upper bool) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if upper {
+			s = strings.ToUpper(s)
+		} else {
+			s = strings.ToLower(s)
+		}
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
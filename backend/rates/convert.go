@@ -0,0 +1,64 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"coin-wave/money"
+)
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+	ErrRateUnavailable = errors.New("exchange rate unavailable")
+)
+
+// Conversion is the rate between two currencies.
+type Conversion struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Rate      money.Decimal `json:"rate"` // Units of To per unit of From
+	UpdatedAt time.Time     `json:"updated_at"`
+	Stale     bool          `json:"stale"`
+}
+
+// Supported reports whether symbol can be converted: USD or a configured
+// currency.
+func Supported(symbol string) bool {
+	return symbol == "USD" || Known(symbol)
+}
+
+// Convert returns the current rate from one currency to another, going
+// through their USD prices. The result is stale if either quote is.
+func (s *Service) Convert(ctx context.Context, from, to string) (Conversion, error) {
+	if !Supported(from) || !Supported(to) {
+		return Conversion{}, ErrUnknownCurrency
+	}
+	conv := Conversion{From: from, To: to, Rate: money.NewFromInt(1), UpdatedAt: time.Now()}
+	if from == to {
+		return conv, nil
+	}
+
+	prices := make(map[string]Quote)
+	for _, q := range s.Quotes(ctx) {
+		prices[q.Symbol] = q
+	}
+	a, okA := prices[from]
+	b, okB := prices[to]
+	if !okA || !okB {
+		return Conversion{}, ErrRateUnavailable
+	}
+	numA, denA := a.usdPrice()
+	numB, denB := b.usdPrice()
+	if numA.Sign() <= 0 || numB.Sign() <= 0 || denA.Sign() <= 0 || denB.Sign() <= 0 {
+		return Conversion{}, ErrRateUnavailable
+	}
+
+	conv.Rate = numA.Mul(denB).Div(denA.Mul(numB))
+	conv.UpdatedAt = a.UpdatedAt
+	if b.UpdatedAt.Before(conv.UpdatedAt) {
+		conv.UpdatedAt = b.UpdatedAt
+	}
+	conv.Stale = a.Stale || b.Stale
+	return conv, nil
+}
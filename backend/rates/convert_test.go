@@ -0,0 +1,81 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"coin-wave/money"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"65000.12", "65000.12", true},
+		{"7.1", "7.1", true},
+		{"0.00001234", "0.00001234", true},
+		{"1.234e-5", "0.00001234", true},
+		{"0", "0", false},
+		{"-1", "-1", false},
+		{"", "0", false},
+		{"abc", "0", false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePrice(tt.in)
+		if ok != tt.ok || got.String() != tt.want {
+			t.Errorf("parsePrice(%q) = %s, %v, want %s, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	s := NewService(nil, nil, nil)
+	quotes, err := mustFixture(t).Fetch(context.Background(), Request{Fiat: FiatSymbols, Crypto: CryptoSymbols})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.store(context.Background(), quotes)
+
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"CNY", "CNY", "1"},
+		{"USD", "CNY", "7.25"},
+		{"BTC", "CNY", "471250"},
+		{"EUR", "CNY", "7.880434782608695652"},
+		{"CNY", "BTC", "0.000002122015915119"},
+		{"BTC", "ETH", "18.571428571428571429"},
+	}
+	for _, tt := range tests {
+		conv, err := s.Convert(context.Background(), tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Convert(%s, %s) error = %v", tt.from, tt.to, err)
+			continue
+		}
+		if conv.Rate.String() != tt.want || conv.Stale {
+			t.Errorf("Convert(%s, %s) = %s (stale %v), want %s", tt.from, tt.to, conv.Rate, conv.Stale, tt.want)
+		}
+	}
+
+	// A price charged at the rate is exact, not off by a float rounding
+	price := money.New(money.MustParse("19.99"), "USD")
+	conv, _ := s.Convert(context.Background(), "USD", "CNY")
+	if got := price.Convert(conv.Rate, "CNY").Round(2).Amount; got.String() != "144.93" {
+		t.Errorf("19.99 USD = %s CNY, want 144.93", got)
+	}
+
+	if _, err := s.Convert(context.Background(), "XXX", "CNY"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Convert() of an unknown currency = %v, want ErrUnknownCurrency", err)
+	}
+
+	// Quotes cached by an older version only have PriceUSD
+	s.store(context.Background(), []Quote{{Symbol: "GBP", Kind: KindFiat, PriceUSD: 1.25, UpdatedAt: time.Now()}})
+	if conv, err := s.Convert(context.Background(), "GBP", "USD"); err != nil || conv.Rate.String() != "1.25" {
+		t.Errorf("Convert(GBP, USD) = %s, %v, want 1.25", conv.Rate, err)
+	}
+}
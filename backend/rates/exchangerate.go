@@ -0,0 +1,54 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ExchangeRateProvider quotes fiat currencies from the free open.er-api.com API.
+type ExchangeRateProvider struct {
+	client *resty.Client
+}
+
+type exchangeRateResponse struct {
+	Result    string                 `json:"result"`
+	ErrorType string                 `json:"error-type"`
+	Rates     map[string]json.Number `json:"rates"` // Units per USD
+}
+
+func NewExchangeRateProvider() *ExchangeRateProvider {
+	return &ExchangeRateProvider{client: resty.New().SetBaseURL(ExchangeRateBaseURL).SetTimeout(FetchTimeout)}
+}
+
+func (p *ExchangeRateProvider) Name() string { return "exchangerate" }
+
+func (p *ExchangeRateProvider) Fetch(ctx context.Context, req Request) ([]Quote, error) {
+	if len(req.Fiat) == 0 {
+		return nil, nil
+	}
+
+	var body exchangeRateResponse
+	resp, err := p.client.R().
+		SetContext(ctx).
+		SetResult(&body).
+		Get("/latest/USD")
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() || body.Result != "success" {
+		return nil, fmt.Errorf("exchangerate error: %s %s", resp.Status(), body.ErrorType)
+	}
+
+	now := time.Now()
+	var quotes []Quote
+	for _, s := range req.Fiat {
+		if rate, ok := parsePrice(body.Rates[s].String()); ok {
+			quotes = append(quotes, newFiatQuote(s, rate, p.Name(), now))
+		}
+	}
+	return quotes, nil
+}
@@ -0,0 +1,60 @@
+package rates
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+//go:embed fixture.json
+var defaultFixture []byte
+
+// fixtureFile uses the same units as the legacy /rates response: fiat in units
+// per USD, crypto in USD per unit.
+type fixtureFile struct {
+	Fiat   map[string]json.Number `json:"fiat"`
+	Crypto map[string]json.Number `json:"crypto"`
+}
+
+// FixtureProvider serves fixed quotes from a JSON file, for offline
+// development and tests.
+type FixtureProvider struct {
+	data fixtureFile
+}
+
+// NewFixtureProvider loads the fixture at path, or the built-in one if path is empty.
+func NewFixtureProvider(path string) (*FixtureProvider, error) {
+	raw := defaultFixture
+	if path != "" {
+		var err error
+		if raw, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var data fixtureFile
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("invalid rates fixture: %w", err)
+	}
+	return &FixtureProvider{data: data}, nil
+}
+
+func (p *FixtureProvider) Name() string { return "fixture" }
+
+func (p *FixtureProvider) Fetch(ctx context.Context, req Request) ([]Quote, error) {
+	now := time.Now()
+	var quotes []Quote
+	for _, s := range req.Fiat {
+		if rate, ok := parsePrice(p.data.Fiat[s].String()); ok {
+			quotes = append(quotes, newFiatQuote(s, rate, p.Name(), now))
+		}
+	}
+	for _, s := range req.Crypto {
+		if price, ok := parsePrice(p.data.Crypto[s].String()); ok {
+			quotes = append(quotes, newCryptoQuote(s, price, p.Name(), now))
+		}
+	}
+	return quotes, nil
+}
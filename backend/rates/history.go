@@ -0,0 +1,217 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"coin-wave/models"
+
+	"gorm.io/gorm"
+)
+
+// Intervals are the candle sizes History accepts.
+var Intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+const (
+	downsampleEvery = time.Hour
+	downsampleLock  = "rates:downsample:lock"
+)
+
+// Candle is an OHLC candle of a symbol's price in USD.
+type Candle struct {
+	Time    time.Time `json:"time"` // Start of the bucket
+	Open    float64   `json:"open"`
+	High    float64   `json:"high"`
+	Low     float64   `json:"low"`
+	Close   float64   `json:"close"`
+	Samples int       `json:"samples"`
+}
+
+// bucket accumulates prices into a candle. Open and close come from the
+// earliest and latest samples; ties keep the value added first.
+type bucket struct {
+	Candle
+	first, last time.Time
+}
+
+func (b *bucket) add(first, last time.Time, open, high, low, close float64, samples int) {
+	if b.Samples == 0 {
+		b.Open, b.High, b.Low, b.Close = open, high, low, close
+		b.first, b.last = first, last
+		b.Samples = samples
+		return
+	}
+	if first.Before(b.first) {
+		b.Open, b.first = open, first
+	}
+	if last.After(b.last) {
+		b.Close, b.last = close, last
+	}
+	b.High = max(b.High, high)
+	b.Low = min(b.Low, low)
+	b.Samples += samples
+}
+
+// Known reports whether symbol is one of the configured currencies.
+func Known(symbol string) bool {
+	return slices.Contains(FiatSymbols, symbol) || slices.Contains(CryptoSymbols, symbol)
+}
+
+// recordSnapshots stores freshly fetched quotes for History.
+func (s *Service) recordSnapshots(quotes []Quote) {
+	if s.db == nil || len(quotes) == 0 {
+		return
+	}
+	rows := make([]models.RateSnapshot, len(quotes))
+	for i, q := range quotes {
+		rows[i] = models.RateSnapshot{Symbol: q.Symbol, PriceUSD: q.PriceUSD, Source: q.Source, CreatedAt: q.UpdatedAt}
+	}
+	if err := s.db.Create(&rows).Error; err != nil {
+		log.Printf("[Rates] Failed to store snapshots: %v", err)
+	}
+}
+
+// History returns the candles of symbol over [from, to), built from whatever
+// resolution the data is still kept at. Downsampled data yields at most one
+// candle per hour or day however small the interval is.
+func (s *Service) History(symbol string, interval time.Duration, from, to time.Time) ([]Candle, error) {
+	buckets := make(map[candleKey]*bucket)
+
+	var candles []models.RateCandle
+	err := s.db.Where("symbol = ? AND start >= ? AND start < ?", symbol, from, to).
+		Order("start").Find(&candles).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candles {
+		addToBucket(buckets, symbol, interval, c.Start, c.Open, c.High, c.Low, c.Close, c.Samples)
+	}
+
+	var snapshots []models.RateSnapshot
+	err = s.db.Select("price_usd, created_at").
+		Where("symbol = ? AND created_at >= ? AND created_at < ?", symbol, from, to).
+		Order("created_at").Find(&snapshots).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, snap := range snapshots {
+		addToBucket(buckets, symbol, interval, snap.CreatedAt, snap.PriceUSD, snap.PriceUSD, snap.PriceUSD, snap.PriceUSD, 1)
+	}
+
+	out := make([]Candle, 0, len(buckets))
+	for _, b := range buckets {
+		out = append(out, b.Candle)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// downsample rolls snapshots older than RawRetention into hourly candles and
+// hourly candles older than HourlyRetention into daily ones. Only whole buckets
+// are rolled up, and a Redis lock keeps instances from doing it twice.
+func (s *Service) downsample(ctx context.Context, now time.Time) {
+	if s.db == nil {
+		return
+	}
+	if s.rdb != nil {
+		ok, err := s.rdb.SetNX(ctx, downsampleLock, 1, 10*time.Minute).Result()
+		if err != nil || !ok {
+			return
+		}
+		defer s.rdb.Del(ctx, downsampleLock)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		cutoff := now.Add(-RawRetention).Truncate(time.Hour)
+		buckets := make(map[candleKey]*bucket)
+		var batch []models.RateSnapshot
+		err := tx.Where("created_at < ?", cutoff).FindInBatches(&batch, 1000, func(_ *gorm.DB, _ int) error {
+			for _, snap := range batch {
+				addToBucket(buckets, snap.Symbol, time.Hour, snap.CreatedAt, snap.PriceUSD, snap.PriceUSD, snap.PriceUSD, snap.PriceUSD, 1)
+			}
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := saveCandles(tx, "1h", buckets); err != nil {
+			return err
+		}
+		if err := tx.Where("created_at < ?", cutoff).Delete(&models.RateSnapshot{}).Error; err != nil {
+			return err
+		}
+
+		cutoff = now.Add(-HourlyRetention).Truncate(24 * time.Hour)
+		buckets = make(map[candleKey]*bucket)
+		var hourly []models.RateCandle
+		err = tx.Where("resolution = ? AND start < ?", "1h", cutoff).FindInBatches(&hourly, 1000, func(_ *gorm.DB, _ int) error {
+			for _, c := range hourly {
+				addToBucket(buckets, c.Symbol, 24*time.Hour, c.Start, c.Open, c.High, c.Low, c.Close, c.Samples)
+			}
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := saveCandles(tx, "1d", buckets); err != nil {
+			return err
+		}
+		return tx.Where("resolution = ? AND start < ?", "1h", cutoff).Delete(&models.RateCandle{}).Error
+	})
+	if err != nil {
+		log.Printf("[Rates] Downsampling failed: %v", err)
+	}
+}
+
+type candleKey struct {
+	symbol string
+	start  time.Time
+}
+
+func addToBucket(buckets map[candleKey]*bucket, symbol string, size time.Duration, at time.Time, open, high, low, close float64, samples int) {
+	key := candleKey{symbol, at.Truncate(size)}
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{Candle: Candle{Time: key.start}}
+		buckets[key] = b
+	}
+	b.add(at, at, open, high, low, close, samples)
+}
+
+// saveCandles writes the buckets as candles of the given resolution, merging
+// them into candles already stored for the same bucket.
+func saveCandles(tx *gorm.DB, resolution string, buckets map[candleKey]*bucket) error {
+	for key, b := range buckets {
+		var existing models.RateCandle
+		err := tx.Where("symbol = ? AND resolution = ? AND start = ?", key.symbol, resolution, key.start).
+			First(&existing).Error
+		if err == nil {
+			// The stored candle covers earlier samples of the bucket
+			merged := &bucket{Candle: Candle{Time: key.start}}
+			merged.add(key.start, key.start, existing.Open, existing.High, existing.Low, existing.Close, existing.Samples)
+			merged.add(b.first, b.last, b.Open, b.High, b.Low, b.Close, b.Samples)
+			b = merged
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		existing.Symbol, existing.Resolution, existing.Start = key.symbol, resolution, key.start
+		existing.Open, existing.High, existing.Low, existing.Close = b.Open, b.High, b.Low, b.Close
+		existing.Samples = b.Samples
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"coin-wave/money"
+)
+
+// Quote kinds
+const (
+	KindFiat   = "fiat"
+	KindCrypto = "crypto"
+)
+
+// Quote is the USD value of one unit of a currency.
+type Quote struct {
+	Symbol    string        `json:"symbol"`
+	Kind      string        `json:"kind"`
+	PriceUSD  float64       `json:"price_usd"`  // For display, history and alerts
+	Rate      money.Decimal `json:"rate"`       // As published, units per USD for fiat and USD per unit for crypto
+	Source    string        `json:"source"`     // Provider that supplied the price
+	UpdatedAt time.Time     `json:"updated_at"` // When the price was fetched
+	Stale     bool          `json:"stale"`      // Not refreshed within StaleAfter
+}
+
+// newCryptoQuote builds a quote from a price in USD per unit.
+func newCryptoQuote(symbol string, price money.Decimal, source string, at time.Time) Quote {
+	return Quote{Symbol: symbol, Kind: KindCrypto, PriceUSD: price.Float64(), Rate: price, Source: source, UpdatedAt: at}
+}
+
+// newFiatQuote builds a quote from a rate in units per USD.
+func newFiatQuote(symbol string, perUSD money.Decimal, source string, at time.Time) Quote {
+	return Quote{Symbol: symbol, Kind: KindFiat, PriceUSD: 1 / perUSD.Float64(), Rate: perUSD, Source: source, UpdatedAt: at}
+}
+
+// usdPrice returns the USD value of one unit as num/den, so conversions
+// divide once at the end instead of inverting fiat rates.
+func (q Quote) usdPrice() (num, den money.Decimal) {
+	rate := q.Rate
+	if rate.IsZero() && q.PriceUSD > 0 {
+		// Cached before Rate existed
+		if q.Kind == KindFiat {
+			rate = money.NewFromFloat(1 / q.PriceUSD)
+		} else {
+			rate = money.NewFromFloat(q.PriceUSD)
+		}
+	}
+	if q.Kind == KindFiat {
+		return money.NewFromInt(1), rate
+	}
+	return rate, money.NewFromInt(1)
+}
+
+// parsePrice reads a price from a provider response without going through
+// float64, unless it is in exponent notation. It fails on non-positive prices.
+func parsePrice(s string) (money.Decimal, bool) {
+	d, err := money.Parse(s)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil {
+			return money.Zero, false
+		}
+		d = money.NewFromFloat(f)
+	}
+	return d, d.Sign() > 0
+}
+
+// Request lists the symbols to fetch by kind.
+type Request struct {
+	Fiat   []string
+	Crypto []string
+}
+
+func (r Request) Empty() bool {
+	return len(r.Fiat) == 0 && len(r.Crypto) == 0
+}
+
+// Provider fetches quotes. Providers skip the symbols they do not support,
+// so a partial result is not an error.
+type Provider interface {
+	Name() string
+	Fetch(ctx context.Context, req Request) ([]Quote, error)
+}
+
+// NewProvider builds a provider by name.
+func NewProvider(name string) (Provider, error) {
+	switch name {
+	case "coingecko":
+		return NewCoinGeckoProvider(), nil
+	case "binance":
+		return NewBinanceProvider(), nil
+	case "exchangerate":
+		return NewExchangeRateProvider(), nil
+	case "fixture":
+		return NewFixtureProvider(FixturePath)
+	default:
+		return nil, fmt.Errorf("unknown rates provider: %s", name)
+	}
+}
+
+// NewProviderChain builds the fallback chain configured in Providers.
+func NewProviderChain() (*Chain, error) {
+	return newChain(Providers)
+}
+
+// DefaultProviderChain builds the chain of DefaultProviders.
+func DefaultProviderChain() *Chain {
+	chain, err := newChain(DefaultProviders)
+	if err != nil {
+		panic(err) // DefaultProviders only names built-in providers
+	}
+	return chain
+}
+
+func newChain(names []string) (*Chain, error) {
+	var providers []Provider
+	for _, name := range names {
+		p, err := NewProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no rates providers configured")
+	}
+	return &Chain{providers: providers}, nil
+}
+
+// Chain asks each provider in turn for the symbols still missing, so a failing
+// or partial provider falls back to the next one. Quotes without a positive
+// price are dropped and asked of the next provider too.
+type Chain struct {
+	providers []Provider
+}
+
+func (c *Chain) Name() string {
+	names := make([]string, len(c.providers))
+	for i, p := range c.providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *Chain) Fetch(ctx context.Context, req Request) ([]Quote, error) {
+	var quotes []Quote
+	var errs []error
+	for _, p := range c.providers {
+		if req.Empty() {
+			break
+		}
+		got, err := p.Fetch(ctx, req)
+		if err != nil {
+			log.Printf("[Rates] Provider %s failed: %v", p.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+		got = slices.DeleteFunc(got, func(q Quote) bool {
+			if q.PriceUSD > 0 && !math.IsInf(q.PriceUSD, 0) {
+				return false
+			}
+			log.Printf("[Rates] Provider %s returned price %v for %s, skipping", p.Name(), q.PriceUSD, q.Symbol)
+			return true
+		})
+		quotes = append(quotes, got...)
+		req = missing(req, got)
+	}
+	if len(quotes) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return quotes, nil
+}
+
+// missing removes the fetched symbols from req.
+func missing(req Request, got []Quote) Request {
+	have := make(map[string]bool, len(got))
+	for _, q := range got {
+		have[q.Kind+":"+q.Symbol] = true
+	}
+	var out Request
+	for _, s := range req.Fiat {
+		if !have[KindFiat+":"+s] {
+			out.Fiat = append(out.Fiat, s)
+		}
+	}
+	for _, s := range req.Crypto {
+		if !have[KindCrypto+":"+s] {
+			out.Crypto = append(out.Crypto, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubProvider returns fixed quotes for the symbols it knows, or an error.
+type stubProvider struct {
+	name   string
+	prices map[string]float64 // Kind:Symbol -> USD price
+	err    error
+	asked  []Request
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) Fetch(_ context.Context, req Request) ([]Quote, error) {
+	p.asked = append(p.asked, req)
+	if p.err != nil {
+		return nil, p.err
+	}
+	var quotes []Quote
+	for _, s := range req.Fiat {
+		if price, ok := p.prices[KindFiat+":"+s]; ok {
+			quotes = append(quotes, Quote{Symbol: s, Kind: KindFiat, PriceUSD: price, Source: p.name})
+		}
+	}
+	for _, s := range req.Crypto {
+		if price, ok := p.prices[KindCrypto+":"+s]; ok {
+			quotes = append(quotes, Quote{Symbol: s, Kind: KindCrypto, PriceUSD: price, Source: p.name})
+		}
+	}
+	return quotes, nil
+}
+
+func mustFixture(t *testing.T) *FixtureProvider {
+	t.Helper()
+	p, err := NewFixtureProvider("")
+	if err != nil {
+		t.Fatalf("NewFixtureProvider: %v", err)
+	}
+	return p
+}
+
+func TestFixtureProvider(t *testing.T) {
+	quotes, err := mustFixture(t).Fetch(context.Background(), Request{Fiat: []string{"CNY", "XXX"}, Crypto: []string{"BTC"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Quote)
+	for _, q := range quotes {
+		got[q.Symbol] = q
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d quotes, want CNY and BTC only: %+v", len(quotes), quotes)
+	}
+	// Fiat is stored in units per USD and returned as USD per unit
+	if q := got["CNY"]; q.Kind != KindFiat || q.PriceUSD != 1/7.25 {
+		t.Errorf("CNY = %+v, want %v USD", q, 1/7.25)
+	}
+	if q := got["BTC"]; q.Kind != KindCrypto || q.PriceUSD != 65000 || q.Source != "fixture" {
+		t.Errorf("BTC = %+v", q)
+	}
+}
+
+func TestChainFallback(t *testing.T) {
+	req := Request{Fiat: []string{"EUR", "CNY"}, Crypto: []string{"BTC", "ETH"}}
+
+	tests := []struct {
+		name    string
+		chain   func(t *testing.T) []Provider
+		sources map[string]string // Symbol -> provider that should supply it
+		wantErr bool
+	}{
+		{
+			name: "first provider failing falls back to the fixture",
+			chain: func(t *testing.T) []Provider {
+				return []Provider{&stubProvider{name: "down", err: errors.New("timeout")}, mustFixture(t)}
+			},
+			sources: map[string]string{"EUR": "fixture", "CNY": "fixture", "BTC": "fixture", "ETH": "fixture"},
+		},
+		{
+			name: "partial provider is completed by the next",
+			chain: func(t *testing.T) []Provider {
+				return []Provider{
+					&stubProvider{name: "crypto", prices: map[string]float64{"crypto:BTC": 70000}},
+					mustFixture(t),
+				}
+			},
+			sources: map[string]string{"EUR": "fixture", "CNY": "fixture", "BTC": "crypto", "ETH": "fixture"},
+		},
+		{
+			name: "every provider failing is an error",
+			chain: func(t *testing.T) []Provider {
+				return []Provider{
+					&stubProvider{name: "a", err: errors.New("down")},
+					&stubProvider{name: "b", err: errors.New("down")},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "non-positive prices are asked of the next provider",
+			chain: func(t *testing.T) []Provider {
+				return []Provider{
+					&stubProvider{name: "broken", prices: map[string]float64{"fiat:EUR": 0, "crypto:BTC": -1, "crypto:ETH": 3600}},
+					mustFixture(t),
+				}
+			},
+			sources: map[string]string{"EUR": "fixture", "CNY": "fixture", "BTC": "fixture", "ETH": "broken"},
+		},
+		{
+			name: "symbols no provider knows are left out",
+			chain: func(t *testing.T) []Provider {
+				return []Provider{&stubProvider{name: "eur", prices: map[string]float64{"fiat:EUR": 1.08}}}
+			},
+			sources: map[string]string{"EUR": "eur"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &Chain{providers: tt.chain(t)}
+			quotes, err := chain.Fetch(context.Background(), req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", quotes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(quotes) != len(tt.sources) {
+				t.Fatalf("got %d quotes, want %d: %+v", len(quotes), len(tt.sources), quotes)
+			}
+			for _, q := range quotes {
+				if want := tt.sources[q.Symbol]; q.Source != want {
+					t.Errorf("%s from %q, want %q", q.Symbol, q.Source, want)
+				}
+			}
+		})
+	}
+}
+
+func TestChainOnlyAsksForMissingSymbols(t *testing.T) {
+	first := &stubProvider{name: "first", prices: map[string]float64{"fiat:EUR": 1.08, "crypto:BTC": 70000}}
+	second := &stubProvider{name: "second"}
+	chain := &Chain{providers: []Provider{first, second}}
+
+	if _, err := chain.Fetch(context.Background(), Request{Fiat: []string{"EUR"}, Crypto: []string{"BTC", "ETH"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.asked) != 1 {
+		t.Fatalf("second provider asked %d times, want 1", len(second.asked))
+	}
+	if got := second.asked[0]; len(got.Fiat) != 0 || len(got.Crypto) != 1 || got.Crypto[0] != "ETH" {
+		t.Errorf("second provider asked for %+v, want ETH only", got)
+	}
+
+	// Nothing left to fetch, the second provider is not called
+	second.asked = nil
+	if _, err := chain.Fetch(context.Background(), Request{Fiat: []string{"EUR"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.asked) != 0 {
+		t.Errorf("second provider asked %+v, want no call", second.asked)
+	}
+}
+
+func TestNewProviderChain(t *testing.T) {
+	saved := Providers
+	defer func() { Providers = saved }()
+
+	Providers = []string{"fixture"}
+	chain, err := NewProviderChain()
+	if err != nil || chain.Name() != "fixture" {
+		t.Fatalf("NewProviderChain() = %v, %v", chain, err)
+	}
+	if got := DefaultProviderChain().Name(); got != "coingecko,binance,exchangerate" {
+		t.Errorf("DefaultProviderChain() = %s", got)
+	}
+
+	for _, bad := range [][]string{{"coingeko"}, {}} {
+		Providers = bad
+		if _, err := NewProviderChain(); err == nil {
+			t.Errorf("NewProviderChain() with %q succeeded, want an error", bad)
+		}
+	}
+}
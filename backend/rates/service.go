@@ -0,0 +1,158 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"coin-wave/money"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const quoteKeyPrefix = "rates:quote:"
+
+// Service refreshes quotes in the background and serves them from Redis, so
+// every API instance shares one view. The last quotes are also kept in memory
+// in case Redis is unavailable. Every refresh is also stored as snapshots for
+// History.
+type Service struct {
+	db       *gorm.DB
+	provider Provider
+	rdb      *redis.Client
+
+	mu   sync.RWMutex
+	last map[string]Quote // Symbol -> latest quote
+}
+
+func NewService(db *gorm.DB, provider Provider, rdb *redis.Client) *Service {
+	return &Service{db: db, provider: provider, rdb: rdb, last: make(map[string]Quote)}
+}
+
+// Start refreshes in the background right away, then every RefreshInterval
+// until ctx is cancelled. Old snapshots are downsampled every hour.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		s.Refresh(ctx)
+		ticker := time.NewTicker(RefreshInterval)
+		defer ticker.Stop()
+		var lastDownsample time.Time
+		for {
+			if now := time.Now(); now.Sub(lastDownsample) >= downsampleEvery {
+				s.downsample(ctx, now)
+				lastDownsample = now
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Refresh(ctx)
+			}
+		}
+	}()
+	log.Printf("[Rates] Refreshing every %s from %s", RefreshInterval, s.provider.Name())
+}
+
+// Refresh fetches all configured symbols and caches what was returned.
+// Symbols that could not be fetched keep their previous quote, which turns
+// stale after StaleAfter.
+func (s *Service) Refresh(ctx context.Context) {
+	fetchCtx, cancel := context.WithTimeout(ctx, 2*FetchTimeout)
+	defer cancel()
+
+	quotes, err := s.provider.Fetch(fetchCtx, Request{Fiat: FiatSymbols, Crypto: CryptoSymbols})
+	if err != nil {
+		log.Printf("[Rates] Refresh failed: %v", err)
+		return
+	}
+	s.store(ctx, quotes)
+	s.recordSnapshots(quotes)
+}
+
+func (s *Service) store(ctx context.Context, quotes []Quote) {
+	s.mu.Lock()
+	for _, q := range quotes {
+		s.last[q.Symbol] = q
+	}
+	s.mu.Unlock()
+
+	if s.rdb == nil || len(quotes) == 0 {
+		return
+	}
+	pipe := s.rdb.Pipeline()
+	for _, q := range quotes {
+		data, _ := json.Marshal(q)
+		pipe.Set(ctx, quoteKeyPrefix+q.Symbol, data, 0)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Rates] Failed to cache quotes: %v", err)
+	}
+}
+
+// Quotes returns the cached quote of every configured symbol that has one,
+// with Stale set on those older than StaleAfter. USD is always included.
+func (s *Service) Quotes(ctx context.Context) []Quote {
+	symbols := append(append([]string{}, FiatSymbols...), CryptoSymbols...)
+
+	quotes := make(map[string]Quote)
+	s.mu.RLock()
+	for _, sym := range symbols {
+		if q, ok := s.last[sym]; ok {
+			quotes[sym] = q
+		}
+	}
+	s.mu.RUnlock()
+
+	// Redis may hold newer quotes written by another instance
+	if s.rdb != nil {
+		keys := make([]string, len(symbols))
+		for i, sym := range symbols {
+			keys[i] = quoteKeyPrefix + sym
+		}
+		if vals, err := s.rdb.MGet(ctx, keys...).Result(); err == nil {
+			for _, v := range vals {
+				str, ok := v.(string)
+				if !ok {
+					continue
+				}
+				var q Quote
+				if json.Unmarshal([]byte(str), &q) == nil && q.UpdatedAt.After(quotes[q.Symbol].UpdatedAt) {
+					quotes[q.Symbol] = q
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	out := []Quote{newFiatQuote("USD", money.NewFromInt(1), "base", now)}
+	for _, q := range quotes {
+		q.Stale = now.Sub(q.UpdatedAt) > StaleAfter
+		out = append(out, q)
+	}
+	sort.SliceStable(out[1:], func(i, j int) bool {
+		a, b := out[1+i], out[1+j]
+		if a.Kind != b.Kind {
+			return a.Kind == KindFiat
+		}
+		return a.Symbol < b.Symbol
+	})
+	return out
+}
+
+// Legacy converts quotes to the original /rates map: fiat currencies in units
+// per USD, crypto currencies in USD per unit.
+func Legacy(quotes []Quote) map[string]float64 {
+	m := make(map[string]float64, len(quotes))
+	for _, q := range quotes {
+		if q.Kind == KindFiat {
+			m[q.Symbol] = 1 / q.PriceUSD
+		} else {
+			m[q.Symbol] = q.PriceUSD
+		}
+	}
+	return m
+}
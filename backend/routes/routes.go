@@ -3,6 +3,7 @@ package routes
 import (
 	"coin-wave/controllers"
 	"coin-wave/middleware"
+	"coin-wave/payments"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,7 +29,7 @@ func SetupRoutes(r *gin.Engine) {
 			compatArticles.POST("", middleware.AuthMiddleware(), controllers.CreateArticle)
 			compatArticles.DELETE("/:id", middleware.AuthMiddleware(), controllers.DeleteArticle)
 			compatArticles.POST("/:id/bookmark", middleware.AuthMiddleware(), controllers.BookmarkArticle)
-			compatArticles.POST("/:id/purchase", middleware.AuthMiddleware(), controllers.PurchaseArticle)
+			compatArticles.POST("/:id/purchase", middleware.AuthMiddleware(), middleware.IdempotencyMiddleware(), controllers.PurchaseArticle)
 			compatArticles.POST("/:id/reindex", middleware.AuthMiddleware(), controllers.ReIndexArticle)
 		}
 
@@ -44,7 +45,7 @@ func SetupRoutes(r *gin.Engine) {
 		compatWallet := compat.Group("/wallet")
 		compatWallet.Use(middleware.AuthMiddleware())
 		{
-			compatWallet.POST("/deposit", controllers.Deposit)
+			compatWallet.POST("/deposit", middleware.IdempotencyMiddleware(), controllers.Deposit)
 			compatWallet.GET("/balance", controllers.GetBalance)
 		}
 
@@ -71,14 +72,19 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			// Public
 			articles.GET("", middleware.OptionalAuthMiddleware(), controllers.GetArticles)
+			articles.GET("/semantic-search", middleware.OptionalAuthMiddleware(), controllers.SemanticSearchArticles)
 			articles.GET("/:id", middleware.OptionalAuthMiddleware(), controllers.GetArticle)
+			articles.GET("/:id/related", middleware.OptionalAuthMiddleware(), controllers.GetRelatedArticles)
 			
 			// Protected
 			articles.POST("", middleware.AuthMiddleware(), controllers.CreateArticle)
 			articles.DELETE("/:id", middleware.AuthMiddleware(), controllers.DeleteArticle)
 			articles.POST("/:id/bookmark", middleware.AuthMiddleware(), controllers.BookmarkArticle)
-			articles.POST("/:id/purchase", middleware.AuthMiddleware(), controllers.PurchaseArticle)
+			articles.POST("/:id/quote", middleware.AuthMiddleware(), controllers.CreatePriceQuote)
+			articles.POST("/:id/purchase", middleware.AuthMiddleware(), middleware.IdempotencyMiddleware(), controllers.PurchaseArticle)
 			articles.POST("/:id/reindex", middleware.AuthMiddleware(), controllers.ReIndexArticle)
+			articles.GET("/:id/index-status", middleware.AuthMiddleware(), controllers.GetIndexStatus)
+			articles.GET("/:id/index-status/stream", middleware.AuthMiddleware(), controllers.StreamIndexStatus)
 		}
 
 		// User Routes
@@ -87,25 +93,71 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			user.GET("/articles", controllers.GetUserArticles)
 			user.GET("/bookmarks", controllers.GetUserBookmarks)
+			user.GET("/tags", controllers.GetFollowedTags)
+			user.POST("/tags", controllers.FollowTag)
+			user.DELETE("/tags/:tag", controllers.UnfollowTag)
 		}
 
+		// Personalized Feed
+		v1.GET("/feed", middleware.AuthMiddleware(), controllers.GetFeed)
+
 		// Wallet Routes
 		wallet := v1.Group("/wallet")
 		wallet.Use(middleware.AuthMiddleware())
 		{
-			wallet.POST("/deposit", controllers.Deposit)
+			wallet.POST("/deposit", middleware.IdempotencyMiddleware(), controllers.Deposit)
 			wallet.GET("/balance", controllers.GetBalance)
+			wallet.GET("/transactions", controllers.GetWalletTransactions)
+			wallet.GET("/transactions/export", controllers.ExportWalletTransactions)
+			wallet.GET("/statement", controllers.GetWalletStatement)
+			wallet.GET("/deposits/:id", controllers.GetDepositIntent)
+			if payments.MockEnabled {
+				wallet.POST("/deposits/:id/mock-complete", controllers.CompleteMockPayment)
+			}
 		}
+
+		// Payment provider callbacks, verified by signature
+		v1.POST("/payments/webhook/:provider", controllers.PaymentWebhook)
 		
+		// Price Alerts
+		alertGroup := v1.Group("/alerts")
+		alertGroup.Use(middleware.AuthMiddleware())
+		{
+			alertGroup.GET("", controllers.GetAlerts)
+			alertGroup.POST("", controllers.CreateAlert)
+			alertGroup.PATCH("/:id", controllers.UpdateAlert)
+			alertGroup.DELETE("/:id", controllers.DeleteAlert)
+			alertGroup.GET("/notifications", controllers.GetNotifications)
+			alertGroup.POST("/notifications/read-all", controllers.MarkAllNotificationsRead)
+			alertGroup.POST("/notifications/:id/read", controllers.MarkNotificationRead)
+		}
+
 		// RAG Routes
 		ragGroup := v1.Group("/rag")
 		ragGroup.Use(middleware.AuthMiddleware())
 		{
 			ragGroup.POST("/query", controllers.RagQuery)
+			ragGroup.POST("/query/stream", controllers.RagQueryStream)
+
+			// Conversations
+			ragGroup.POST("/sessions", controllers.CreateChatSession)
+			ragGroup.GET("/sessions", controllers.GetChatSessions)
+			ragGroup.GET("/sessions/:id", controllers.GetChatSession)
+			ragGroup.POST("/sessions/:id/messages", controllers.ContinueChatSession)
+			ragGroup.DELETE("/sessions/:id", controllers.DeleteChatSession)
+		}
+
+		// Admin Routes
+		admin := v1.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			admin.GET("/ledger/check", controllers.CheckLedger)
 		}
 
 		// Misc
 		v1.GET("/rankings", middleware.OptionalAuthMiddleware(), controllers.GetRankings)
 		v1.GET("/rates", middleware.OptionalAuthMiddleware(), controllers.GetExchangeRate)
+		v1.GET("/rates/convert", middleware.OptionalAuthMiddleware(), controllers.ConvertCurrency)
+		v1.GET("/rates/:symbol/history", middleware.OptionalAuthMiddleware(), controllers.GetRateHistory)
 	}
 }
@@ -0,0 +1,240 @@
+package search
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ArticleDoc is the searchable view of an article.
+type ArticleDoc struct {
+	ID         uint
+	Title      string
+	Content    string
+	Tags       string // Comma separated
+	AuthorID   uint
+	AuthorName string
+	IsPaid     bool
+	Price      float64
+	Currency   string // Price currency, empty is the wallet currency
+}
+
+// ArticleQuery is a full-text query with facet filters. Empty filters match everything.
+type ArticleQuery struct {
+	Text     string
+	Type     string // "free" or "paid"
+	Tag      string
+	AuthorID uint
+	Price    string // One of the price buckets, see PriceBucket
+
+	// Wallet currency units per unit of each price currency. Prices are
+	// bucketed in the wallet currency; articles priced in a currency without
+	// a rate are left out of the price facet and filter.
+	Rates map[string]float64
+}
+
+// FacetCount is one value of a facet and the number of matching articles.
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// Facets summarises the matching articles.
+type Facets struct {
+	Tags    []FacetCount `json:"tags"`
+	Price   []FacetCount `json:"price"`
+	Authors []FacetCount `json:"authors"`
+}
+
+// Facet sizes
+const (
+	maxTagFacets    = 20
+	maxAuthorFacets = 10
+)
+
+// Price buckets in display order
+var priceBuckets = []string{"free", "0-10", "10-50", "50-100", "100+"}
+
+// PriceBucket returns the price facet value of an article.
+func PriceBucket(isPaid bool, price float64) string {
+	switch {
+	case !isPaid:
+		return "free"
+	case price < 10:
+		return "0-10"
+	case price < 50:
+		return "10-50"
+	case price < 100:
+		return "50-100"
+	default:
+		return "100+"
+	}
+}
+
+// priceBucket returns the price facet value of doc with its price converted
+// to the wallet currency, or "" when there is no rate for its currency.
+func (q ArticleQuery) priceBucket(doc ArticleDoc) string {
+	if !doc.IsPaid || doc.Currency == "" {
+		return PriceBucket(doc.IsPaid, doc.Price)
+	}
+	rate, ok := q.Rates[doc.Currency]
+	if !ok {
+		return ""
+	}
+	return PriceBucket(true, doc.Price*rate)
+}
+
+// SplitTags parses a comma separated tag list, dropping blanks.
+func SplitTags(tags string) []string {
+	var out []string
+	for _, t := range strings.Split(tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ArticleIndex is the full-text index of articles. It keeps the facet
+// fields of every article in memory; contents are only tokenized.
+type ArticleIndex struct {
+	index *Index
+
+	mu       sync.RWMutex
+	docs     map[uint]ArticleDoc // Without Content
+	gen      uint64              // Bumped on every change, invalidates rankings
+	rankings map[string]*ranking // By ArticleQuery.key
+}
+
+func NewArticleIndex() *ArticleIndex {
+	return &ArticleIndex{
+		index: NewIndex(
+			Field{Name: "title", Weight: 3},
+			Field{Name: "tags", Weight: 2.5},
+			Field{Name: "content", Weight: 1},
+		),
+		docs:     make(map[uint]ArticleDoc),
+		rankings: make(map[string]*ranking),
+	}
+}
+
+// Add indexes an article, replacing a previous version.
+func (a *ArticleIndex) Add(doc ArticleDoc) {
+	a.index.Add(doc.ID, doc.Title, doc.Tags, doc.Content)
+
+	doc.Content = ""
+	a.mu.Lock()
+	a.docs[doc.ID] = doc
+	a.gen++
+	a.mu.Unlock()
+}
+
+// Remove drops an article from the index.
+func (a *ArticleIndex) Remove(id uint) {
+	a.index.Remove(id)
+
+	a.mu.Lock()
+	delete(a.docs, id)
+	a.gen++
+	a.mu.Unlock()
+}
+
+func (a *ArticleIndex) Len() int {
+	return a.index.Len()
+}
+
+// search returns every article matching the text and filters, scored by
+// BM25 alone, together with facet counts over those articles.
+func (a *ArticleIndex) search(q ArticleQuery) ([]Hit, Facets) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	hits := a.index.Search(q.Text, 0, func(id uint) bool {
+		doc, ok := a.docs[id]
+		return ok && q.matches(doc)
+	})
+	return hits, a.facets(q, hits)
+}
+
+func (q ArticleQuery) matches(doc ArticleDoc) bool {
+	if q.Type == "free" && doc.IsPaid || q.Type == "paid" && !doc.IsPaid {
+		return false
+	}
+	if q.AuthorID != 0 && doc.AuthorID != q.AuthorID {
+		return false
+	}
+	if q.Price != "" && q.priceBucket(doc) != q.Price {
+		return false
+	}
+	if q.Tag != "" {
+		for _, t := range SplitTags(doc.Tags) {
+			if strings.EqualFold(t, q.Tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (a *ArticleIndex) facets(q ArticleQuery, hits []Hit) Facets {
+	tags := make(map[string]int)
+	tagNames := make(map[string]string) // Lowercase -> first spelling seen
+	prices := make(map[string]int)
+	authors := make(map[uint]int)
+	authorNames := make(map[uint]string)
+
+	for _, h := range hits {
+		doc := a.docs[h.ID]
+		for _, t := range SplitTags(doc.Tags) {
+			key := strings.ToLower(t)
+			if _, ok := tagNames[key]; !ok {
+				tagNames[key] = t
+			}
+			tags[key]++
+		}
+		if bucket := q.priceBucket(doc); bucket != "" {
+			prices[bucket]++
+		}
+		authors[doc.AuthorID]++
+		authorNames[doc.AuthorID] = doc.AuthorName
+	}
+
+	var f Facets
+	for key, n := range tags {
+		f.Tags = append(f.Tags, FacetCount{Value: tagNames[key], Count: n})
+	}
+	f.Tags = topFacets(f.Tags, maxTagFacets)
+
+	f.Price = []FacetCount{}
+	for _, bucket := range priceBuckets {
+		if n := prices[bucket]; n > 0 {
+			f.Price = append(f.Price, FacetCount{Value: bucket, Count: n})
+		}
+	}
+
+	for id, n := range authors {
+		f.Authors = append(f.Authors, FacetCount{Value: strconv.FormatUint(uint64(id), 10), Label: authorNames[id], Count: n})
+	}
+	f.Authors = topFacets(f.Authors, maxAuthorFacets)
+	return f
+}
+
+// topFacets sorts by count, then value, and keeps the first n.
+func topFacets(counts []FacetCount, n int) []FacetCount {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	if counts == nil {
+		counts = []FacetCount{}
+	}
+	return counts
+}
@@ -0,0 +1,196 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Field is an indexed text field with its weight in the score.
+type Field struct {
+	Name   string
+	Weight float64
+}
+
+// Hit is a matching document.
+type Hit struct {
+	ID    uint
+	Score float64
+}
+
+// Index is an in-memory inverted index ranked with BM25F: term frequencies of
+// all fields are length-normalised per field, weighted, and summed before
+// BM25 saturation. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	fields   []Field
+	postings map[string]map[uint][]int // term -> doc -> frequency per field
+	lengths  map[uint][]int            // doc -> token count per field
+	terms    map[uint][]string         // doc -> distinct terms, for removal
+	totals   []int                     // Sum of lengths per field
+}
+
+func NewIndex(fields ...Field) *Index {
+	return &Index{
+		fields:   fields,
+		postings: make(map[string]map[uint][]int),
+		lengths:  make(map[uint][]int),
+		terms:    make(map[uint][]string),
+		totals:   make([]int, len(fields)),
+	}
+}
+
+// Add indexes a document, replacing any previous version with the same ID.
+// values holds the text of each field, in the order the fields were declared.
+func (idx *Index) Add(id uint, values ...string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+
+	lengths := make([]int, len(idx.fields))
+	var distinct []string
+	for f := range idx.fields {
+		if f >= len(values) {
+			break
+		}
+		terms := Tokenize(values[f])
+		lengths[f] = len(terms)
+		idx.totals[f] += len(terms)
+		for _, term := range terms {
+			docs := idx.postings[term]
+			if docs == nil {
+				docs = make(map[uint][]int)
+				idx.postings[term] = docs
+			}
+			freqs := docs[id]
+			if freqs == nil {
+				freqs = make([]int, len(idx.fields))
+				docs[id] = freqs
+				distinct = append(distinct, term)
+			}
+			freqs[f]++
+		}
+	}
+	idx.lengths[id] = lengths
+	idx.terms[id] = distinct
+}
+
+// Remove drops a document from the index.
+func (idx *Index) Remove(id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id uint) {
+	lengths, ok := idx.lengths[id]
+	if !ok {
+		return
+	}
+	for f, n := range lengths {
+		idx.totals[f] -= n
+	}
+	for _, term := range idx.terms[id] {
+		docs := idx.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.lengths, id)
+	delete(idx.terms, id)
+}
+
+// Len returns the number of indexed documents.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.lengths)
+}
+
+// DocFreq returns the number of documents containing term.
+func (idx *Index) DocFreq(term string) int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.postings[term])
+}
+
+// Terms calls fn for every indexed term with its document frequency.
+func (idx *Index) Terms(fn func(term string, docs int)) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	for term, docs := range idx.postings {
+		fn(term, len(docs))
+	}
+}
+
+// Search returns up to limit documents matching any query term, best first.
+// filter, if not nil, excludes documents for which it returns false.
+// A limit <= 0 returns every match.
+func (idx *Index) Search(query string, limit int, filter func(id uint) bool) []Hit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.lengths))
+	if n == 0 {
+		return nil
+	}
+	avg := make([]float64, len(idx.fields))
+	for f, total := range idx.totals {
+		avg[f] = math.Max(float64(total)/n, 1)
+	}
+
+	scores := make(map[uint]float64)
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		docs := idx.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for id, freqs := range docs {
+			if filter != nil && !filter(id) {
+				continue
+			}
+			lengths := idx.lengths[id]
+			tf := 0.0
+			for f, freq := range freqs {
+				if freq == 0 {
+					continue
+				}
+				norm := 1 - bm25B + bm25B*float64(lengths[f])/avg[f]
+				tf += idx.fields[f].Weight * float64(freq) / norm
+			}
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,35 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Cursor marks the last item of a page. Clients pass it back verbatim as an
+// opaque string to fetch the next page.
+type Cursor struct {
+	Score float64 `json:"s,omitempty"` // Sort key of the last item
+	ID    uint    `json:"id"`          // Tie-breaker, items sort by ID descending
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.ID == 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// After reports whether an item sorted by (score desc, id desc) comes after the cursor.
+func (c Cursor) After(score float64, id uint) bool {
+	return score < c.Score || score == c.Score && id < c.ID
+}
@@ -0,0 +1,43 @@
+package search
+
+import "testing"
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, c := range []Cursor{{ID: 7}, {Score: 12.345678901234567, ID: 42}, {Score: -0.5, ID: 1}} {
+		got, err := DecodeCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%v): %v", c, err)
+		}
+		if got != c {
+			t.Errorf("round trip of %v = %v", c, got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, s := range []string{"", "not base64!", Cursor{Score: 3}.Encode(), "e30"} {
+		if _, err := DecodeCursor(s); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
+
+func TestCursorAfter(t *testing.T) {
+	c := Cursor{Score: 5, ID: 10}
+	tests := []struct {
+		score float64
+		id    uint
+		want  bool
+	}{
+		{4, 99, true},
+		{5, 9, true},
+		{5, 10, false},
+		{5, 11, false},
+		{6, 1, false},
+	}
+	for _, tt := range tests {
+		if got := c.After(tt.score, tt.id); got != tt.want {
+			t.Errorf("After(%v, %d) = %v, want %v", tt.score, tt.id, got, tt.want)
+		}
+	}
+}
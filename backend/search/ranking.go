@@ -0,0 +1,163 @@
+package search
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ranking weights: BM25 relevance blended with popularity
+const (
+	textBoost     = 10  // Weight of the BM25 score against the popularity boosts
+	bookmarkBoost = 2   // Per bookmark
+	viewBoost     = 0.1 // Per view
+)
+
+// Ranking cache settings. A ranking is computed once per query and paged
+// with binary searches until it expires or the index changes, so popularity
+// in the order is at most RankingTTL old.
+var (
+	RankingTTL        = time.Minute
+	maxCachedRankings = 256
+)
+
+// Popularity is the engagement of an article used to boost its rank.
+type Popularity struct {
+	ViewCount     int
+	BookmarkCount int
+}
+
+// PopularityFunc returns the popularity of the given articles. Articles it
+// leaves out are treated as deleted and dropped from the ranking.
+type PopularityFunc func(ids []uint) (map[uint]Popularity, error)
+
+// ArticlePage is one page of ranked search results.
+type ArticlePage struct {
+	Hits   []Hit   // Blended scores, best first
+	Next   *Cursor // Nil on the last page
+	Total  int
+	Facets Facets
+}
+
+type ranking struct {
+	hits    []Hit // Sorted by (score desc, id desc), the cursor order
+	facets  Facets
+	gen     uint64
+	expires time.Time
+}
+
+// SearchPage returns the page of limit articles matching q that follows
+// after, or the first page when after is nil. The full ranking is computed on
+// the first request for a query, loading popularity through pop, and reused
+// for the following pages.
+func (a *ArticleIndex) SearchPage(q ArticleQuery, after *Cursor, limit int, pop PopularityFunc) (ArticlePage, error) {
+	r, err := a.ranking(q, pop)
+	if err != nil {
+		return ArticlePage{}, err
+	}
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(r.hits), func(i int) bool {
+			return after.After(r.hits[i].Score, r.hits[i].ID)
+		})
+	}
+	end := min(start+limit, len(r.hits))
+	page := ArticlePage{
+		Hits:   r.hits[start:end],
+		Total:  len(r.hits),
+		Facets: r.facets,
+	}
+	if end < len(r.hits) {
+		last := r.hits[end-1]
+		page.Next = &Cursor{Score: last.Score, ID: last.ID}
+	}
+	return page, nil
+}
+
+// ranking returns the cached ranking of q, computing it if it is missing,
+// expired or older than the last change to the index.
+func (a *ArticleIndex) ranking(q ArticleQuery, pop PopularityFunc) (*ranking, error) {
+	key := q.key()
+	now := time.Now()
+
+	a.mu.RLock()
+	gen := a.gen
+	r, ok := a.rankings[key]
+	a.mu.RUnlock()
+	if ok && r.gen == gen && now.Before(r.expires) {
+		return r, nil
+	}
+
+	hits, facets := a.search(q)
+	ids := make([]uint, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	pops := map[uint]Popularity{}
+	if len(ids) > 0 {
+		var err error
+		if pops, err = pop(ids); err != nil {
+			return nil, err
+		}
+	}
+	r = &ranking{hits: rank(hits, pops), facets: facets, gen: gen, expires: now.Add(RankingTTL)}
+
+	a.mu.Lock()
+	if len(a.rankings) >= maxCachedRankings {
+		for k, old := range a.rankings {
+			if old.gen != a.gen || now.After(old.expires) {
+				delete(a.rankings, k)
+			}
+		}
+		if len(a.rankings) >= maxCachedRankings {
+			clear(a.rankings)
+		}
+	}
+	a.rankings[key] = r
+	a.mu.Unlock()
+	return r, nil
+}
+
+// rank blends BM25 scores with popularity and sorts in cursor order.
+func rank(hits []Hit, pops map[uint]Popularity) []Hit {
+	ranked := make([]Hit, 0, len(hits))
+	for _, h := range hits {
+		p, ok := pops[h.ID]
+		if !ok {
+			continue // Deleted but not yet dropped from the index
+		}
+		score := h.Score * textBoost
+		score += float64(p.BookmarkCount) * bookmarkBoost
+		score += float64(p.ViewCount) * viewBoost
+		ranked = append(ranked, Hit{ID: h.ID, Score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID > ranked[j].ID
+	})
+	return ranked
+}
+
+// key identifies the results of q in the ranking cache.
+func (q ArticleQuery) key() string {
+	var b strings.Builder
+	b.WriteString(strings.Join(Tokenize(q.Text), " "))
+	for _, v := range []string{q.Type, strings.ToLower(q.Tag), strconv.FormatUint(uint64(q.AuthorID), 10), q.Price} {
+		b.WriteByte(0)
+		b.WriteString(v)
+	}
+	currencies := make([]string, 0, len(q.Rates))
+	for c := range q.Rates {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	for _, c := range currencies {
+		b.WriteByte(0)
+		b.WriteString(c + "=" + strconv.FormatFloat(q.Rates[c], 'g', -1, 64))
+	}
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package search
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func newTestArticleIndex(docs ...ArticleDoc) *ArticleIndex {
+	a := NewArticleIndex()
+	for _, doc := range docs {
+		a.Add(doc)
+	}
+	return a
+}
+
+// countingPopularity serves pops and counts the calls, to observe the cache.
+func countingPopularity(pops map[uint]Popularity, calls *int) PopularityFunc {
+	return func(ids []uint) (map[uint]Popularity, error) {
+		*calls++
+		out := make(map[uint]Popularity)
+		for _, id := range ids {
+			if p, ok := pops[id]; ok {
+				out[id] = p
+			}
+		}
+		return out, nil
+	}
+}
+
+// Five articles with the same text so popularity decides the order
+var rankingDocs = []ArticleDoc{
+	{ID: 1, Title: "Bitcoin outlook", AuthorID: 1},
+	{ID: 2, Title: "Bitcoin outlook", AuthorID: 1},
+	{ID: 3, Title: "Bitcoin outlook", AuthorID: 2},
+	{ID: 4, Title: "Bitcoin outlook", AuthorID: 2, IsPaid: true, Price: 5},
+	{ID: 5, Title: "Bitcoin outlook", AuthorID: 3},
+}
+
+var rankingPops = map[uint]Popularity{
+	1: {BookmarkCount: 3},
+	2: {ViewCount: 20},    // As much as a bookmark
+	3: {BookmarkCount: 1}, // Ties with 2, the higher ID first
+	4: {},
+	5: {BookmarkCount: 9},
+}
+
+func pageIDs(hits []Hit) []uint {
+	var ids []uint
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestSearchPageWalksRanking(t *testing.T) {
+	a := newTestArticleIndex(rankingDocs...)
+	calls := 0
+	pop := countingPopularity(rankingPops, &calls)
+
+	var got [][]uint
+	var after *Cursor
+	for {
+		page, err := a.SearchPage(ArticleQuery{Text: "bitcoin"}, after, 2, pop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 {
+			t.Errorf("Total = %d, want 5", page.Total)
+		}
+		got = append(got, pageIDs(page.Hits))
+		if page.Next == nil {
+			break
+		}
+		// Cursors reach the next page through their encoded form
+		cur, err := DecodeCursor(page.Next.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		after = &cur
+	}
+
+	want := [][]uint{{5, 1}, {3, 2}, {4}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+	if calls != 1 {
+		t.Errorf("popularity loaded %d times, want once per ranking", calls)
+	}
+}
+
+func TestSearchPageDropsDeleted(t *testing.T) {
+	a := newTestArticleIndex(rankingDocs...)
+	calls := 0
+	pops := map[uint]Popularity{1: {}, 2: {}} // The others are deleted
+	page, err := a.SearchPage(ArticleQuery{Text: "bitcoin"}, nil, 10, countingPopularity(pops, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pageIDs(page.Hits); !slices.Equal(got, []uint{2, 1}) || page.Total != 2 || page.Next != nil {
+		t.Errorf("page = %v total %d next %v, want [2 1] total 2 and no next", got, page.Total, page.Next)
+	}
+}
+
+func TestSearchPageRankingCache(t *testing.T) {
+	a := newTestArticleIndex(rankingDocs...)
+	calls := 0
+	pop := countingPopularity(rankingPops, &calls)
+	search := func(q ArticleQuery) ArticlePage {
+		t.Helper()
+		page, err := a.SearchPage(q, nil, 10, pop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	search(ArticleQuery{Text: "bitcoin"})
+	search(ArticleQuery{Text: "Bitcoin "})
+	if calls != 1 {
+		t.Fatalf("same tokens ranked %d times, want 1", calls)
+	}
+
+	if page := search(ArticleQuery{Text: "bitcoin", Type: "paid"}); !slices.Equal(pageIDs(page.Hits), []uint{4}) {
+		t.Errorf("paid filter = %v, want [4]", pageIDs(page.Hits))
+	}
+	if calls != 2 {
+		t.Fatalf("filters share a ranking, %d calls", calls)
+	}
+
+	// Changes to the index invalidate rankings
+	a.Add(ArticleDoc{ID: 6, Title: "Bitcoin outlook", AuthorID: 1})
+	rankingPops[6] = Popularity{BookmarkCount: 100}
+	defer delete(rankingPops, 6)
+	if page := search(ArticleQuery{Text: "bitcoin"}); page.Total != 6 || page.Hits[0].ID != 6 {
+		t.Errorf("after Add = %v, want 6 first of 6", pageIDs(page.Hits))
+	}
+	a.Remove(6)
+	if page := search(ArticleQuery{Text: "bitcoin"}); page.Total != 5 {
+		t.Errorf("after Remove total = %d, want 5", page.Total)
+	}
+	if calls != 4 {
+		t.Errorf("popularity loaded %d times, want 4", calls)
+	}
+}
+
+func TestSearchPagePopularityError(t *testing.T) {
+	a := newTestArticleIndex(rankingDocs...)
+	fail := errors.New("db down")
+	_, err := a.SearchPage(ArticleQuery{Text: "bitcoin"}, nil, 10, func([]uint) (map[uint]Popularity, error) {
+		return nil, fail
+	})
+	if !errors.Is(err, fail) {
+		t.Fatalf("error = %v, want %v", err, fail)
+	}
+	// Failures are not cached
+	calls := 0
+	if _, err := a.SearchPage(ArticleQuery{Text: "bitcoin"}, nil, 10, countingPopularity(rankingPops, &calls)); err != nil || calls != 1 {
+		t.Errorf("retry error = %v with %d calls, want a fresh ranking", err, calls)
+	}
+}
@@ -0,0 +1,82 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Snippet returns an HTML fragment of at most maxRunes runes of text around
+// the first query match, with matched terms wrapped in <em>. The text is
+// escaped, so the result is safe to render as HTML. Without a match it
+// returns the start of the text.
+func Snippet(text, query string, maxRunes int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// Longest terms first, so "比特" is highlighted rather than "比" and "特"
+	var terms [][]rune
+	seen := make(map[string]bool)
+	for _, t := range Tokenize(query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, []rune(t))
+		}
+	}
+	sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+
+	matchAt := func(i int) int {
+		for _, t := range terms {
+			if i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == string(t) {
+				return len(t)
+			}
+		}
+		return 0
+	}
+
+	// Center the window on the first match
+	start := 0
+	for i := range lower {
+		if matchAt(i) > 0 {
+			start = i - maxRunes/4
+			break
+		}
+	}
+	if start > len(runes)-maxRunes {
+		start = len(runes) - maxRunes
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := matchAt(i); n > 0 {
+			if i+n > end {
+				n = end - i
+			}
+			b.WriteString("<em>")
+			b.WriteString(html.EscapeString(string(runes[i : i+n])))
+			b.WriteString("</em>")
+			i += n
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
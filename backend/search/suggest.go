@@ -0,0 +1,113 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Suggest returns a "did you mean" rewrite of the query in which every latin
+// word that is not indexed is replaced by the closest indexed term, or "" if
+// there is nothing to correct. CJK text is left alone.
+func (a *ArticleIndex) Suggest(query string) string {
+	lower := strings.ToLower(query)
+
+	corrections := make(map[string]string)
+	for _, word := range latinWords(lower) {
+		if _, done := corrections[word]; done || len([]rune(word)) < 3 {
+			continue
+		}
+		if a.index.DocFreq(word) > 0 {
+			continue
+		}
+		if fix := a.closestTerm(word); fix != "" {
+			corrections[word] = fix
+		}
+	}
+	if len(corrections) == 0 {
+		return ""
+	}
+
+	// Rebuild the query, replacing whole words only
+	var b strings.Builder
+	var word []rune
+	flush := func() {
+		w := string(word)
+		if fix, ok := corrections[w]; ok {
+			w = fix
+		}
+		b.WriteString(w)
+		word = word[:0]
+	}
+	for _, r := range lower {
+		if isWordRune(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// closestTerm finds the indexed latin term within the allowed edit distance of
+// word, preferring smaller distances and then more frequent terms.
+func (a *ArticleIndex) closestTerm(word string) string {
+	w := []rune(word)
+	maxDist := 1
+	if len(w) > 5 {
+		maxDist = 2
+	}
+
+	best, bestDist, bestDocs := "", maxDist+1, 0
+	a.index.Terms(func(term string, docs int) {
+		t := []rune(term)
+		if abs(len(t)-len(w)) > maxDist || !isWordRune(t[0]) || IsCJK(t[0]) {
+			return
+		}
+		d := editDistance(w, t)
+		if d < bestDist || d == bestDist && (docs > bestDocs || docs == bestDocs && term < best) {
+			best, bestDist, bestDocs = term, d, docs
+		}
+	})
+	if bestDist > maxDist {
+		return ""
+	}
+	return best
+}
+
+func latinWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) })
+}
+
+func isWordRune(r rune) bool {
+	return !IsCJK(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
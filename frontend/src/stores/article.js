@@ -4,6 +4,8 @@ import api from '../api/axios';
 export const useArticleStore = defineStore('article', {
   state: () => ({
     articles: [],
+    nextCursor: '',
+    lastParams: {},
     currentArticle: null,
     loading: false,
   }),
@@ -13,12 +15,28 @@ export const useArticleStore = defineStore('article', {
       try {
         const response = await api.get('/articles', { params });
         this.articles = response.data.data;
+        this.nextCursor = response.data.next_cursor || '';
+        this.lastParams = params;
       } catch (error) {
         console.error('Fetch articles failed', error);
       } finally {
         this.loading = false;
       }
     },
+    async fetchMoreArticles() {
+      if (!this.nextCursor) return;
+      this.loading = true;
+      try {
+        const params = { ...this.lastParams, cursor: this.nextCursor };
+        const response = await api.get('/articles', { params });
+        this.articles.push(...response.data.data);
+        this.nextCursor = response.data.next_cursor || '';
+      } catch (error) {
+        console.error('Fetch more articles failed', error);
+      } finally {
+        this.loading = false;
+      }
+    },
     async fetchArticle(id) {
       this.loading = true;
       try {
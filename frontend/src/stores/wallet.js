@@ -3,7 +3,7 @@ import api from '../api/axios';
 
 export const useWalletStore = defineStore('wallet', {
   state: () => ({
-    balance: 0,
+    balance: '0', // Decimal string from the API
   }),
   actions: {
     async fetchBalance() {
@@ -14,17 +14,30 @@ export const useWalletStore = defineStore('wallet', {
         console.error('Fetch balance failed', error);
       }
     },
-    async deposit(amount) {
-      try {
-        await api.post('/wallet/deposit', { amount: parseFloat(amount) });
-        await this.fetchBalance();
-      } catch (error) {
-        throw error;
-      }
+    // Opens a deposit intent, the balance changes once it is paid. Pass the
+    // same idempotencyKey when retrying, so a retry cannot deposit twice.
+    async deposit(amount, idempotencyKey) {
+      const response = await api.post('/wallet/deposit', { amount: String(amount) }, {
+        headers: { 'Idempotency-Key': idempotencyKey },
+      });
+      return response.data.data;
+    },
+    async fetchDeposit(id) {
+      const response = await api.get(`/wallet/deposits/${id}`);
+      return response.data.data;
+    },
+    // Settles a deposit through the mock payment provider (development only)
+    async completeMockDeposit(id, status = 'succeeded') {
+      const response = await api.post(`/wallet/deposits/${id}/mock-complete`, { status });
+      await this.fetchBalance();
+      return response.data.data;
     },
-    async purchaseArticle(articleId) {
+    // idempotencyKey is made once per checkout and reused on retries
+    async purchaseArticle(articleId, quoteId, idempotencyKey) {
       try {
-        await api.post(`/articles/${articleId}/purchase`);
+        await api.post(`/articles/${articleId}/purchase`, quoteId ? { quote_id: quoteId } : undefined, {
+          headers: { 'Idempotency-Key': idempotencyKey },
+        });
         await this.fetchBalance();
       } catch (error) {
         throw error;